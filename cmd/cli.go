@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
 	"time"
 
@@ -13,58 +18,523 @@ import (
 	"github.com/rifsxd/dvpl_lz4/common/utils"
 )
 
-func Cli() {
-
-	cyan := color.New(color.FgCyan)
+// Process exit codes, documented in the help text.
+const (
+	exitSuccess = 0 // Every file was processed successfully
+	exitFailure = 1 // At least one file failed, or the run itself failed
+	exitUsage   = 2 // Invalid command-line arguments or mode
+)
 
-	fmt.Println()
-	cyan.Println("• Name:", meta.Name)
-	cyan.Println("• Version:", meta.Version)
-	cyan.Println("• Commit:", meta.Commit)
-	cyan.Println("• Dev:", meta.Dev)
-	cyan.Println("• Repo:", meta.Repo)
-	cyan.Println("• Web:", meta.Web)
-	cyan.Println("• Info:", meta.Info)
-	fmt.Println()
+func Cli() {
 
 	startTime := time.Now() // Record start time
 
 	config, err := utils.ParseCommandLineArgs()
+	if err != nil && len(os.Args) > 1 {
+		printBanner()
+		log.Printf("\n%sError%s parsing command-line arguments: %v\n", colors.RedColor, colors.ResetColor, err)
+		os.Exit(exitUsage)
+	}
 	if err != nil {
+		printBanner()
 		log.Printf("\n%sError%s parsing command-line arguments: %v -> %sFallback to GUI mode!%s\n", colors.RedColor, colors.ResetColor, err, colors.YellowColor, colors.ResetColor)
 		Gui() // Fallback to GUI mode if parseCommandLineArgs() errors out
 		return
 	}
 
-	log.SetOutput(os.Stdout)
+	// Stop cleanly after the files in flight when interrupted. The first interrupt restores the
+	// default handler, so a second Ctrl-C exits immediately.
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	stopProfiles, err := startProfiles(config)
+	if err != nil {
+		log.Printf("\n%sError%s starting profiling: %v\n", colors.RedColor, colors.ResetColor, err)
+		os.Exit(exitUsage)
+	}
+	stop := func() {
+		signal.Stop(interrupts)
+		cancel()
+		stopProfiles()
+	}
+	defer stop()
+	go func() {
+		<-interrupts
+		signal.Stop(interrupts)
+		fmt.Fprintf(utils.Output, "\n%sInterrupted%s, finishing the files in progress. Press Ctrl-C again to quit immediately.\n", colors.YellowColor, colors.ResetColor)
+		cancel()
+	}()
 
-	switch config.Mode {
-	case "compress", "decompress":
-		successCount, failureCount, ignoredCount, err := utils.ProcessFiles(config.Path, config)
+	// With -json-summary stdout only carries the summary, everything meant for humans goes to stderr
+	if config.JSONSummary {
+		utils.Output = os.Stderr
+		colors.SetEnabled(os.Getenv("NO_COLOR") == "" && colors.IsTerminal(os.Stderr))
+	}
+
+	// Tee the log into a file, without escape codes
+	if config.LogFile != "" {
+		logFile, err := os.OpenFile(config.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 		if err != nil {
+			log.Printf("\n%sError%s opening log file: %v\n", colors.RedColor, colors.ResetColor, err)
+			stop()
+			os.Exit(exitUsage)
+		}
+		defer logFile.Close()
+		utils.Output = io.MultiWriter(utils.Output, colors.NewStripWriter(logFile))
+	}
+
+	// JSON output owns stdout, so skip the banner and human-readable log
+	if config.JSON && (config.Mode == "compress" || config.Mode == "decompress" || config.Mode == "auto") {
+		if !runJSON(ctx, config, startTime) {
+			stop()
+			os.Exit(exitFailure)
+		}
+		return
+	}
+
+	// Quiet runs stay silent apart from their summary, quiet-on-success ones unless something failed
+	if !config.Quiet && !config.QuietOnSuccess {
+		printBanner()
+	}
+
+	log.SetOutput(utils.Output)
+
+	exitCode := exitSuccess
+
+	// Results of the modes that report per file, for -report
+	var reportResults []utils.Result
+	var reportErr error
+
+	switch config.Mode {
+	case "compress", "decompress", "auto":
+		config.OnResult = func(result utils.Result) {
+			printResult(result, config)
+		}
+		results, err := processPaths(ctx, config)
+		reportResults, reportErr = results, err
+		successCount, failureCount, ignoredCount := utils.CountResults(results)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if config.Manifest != "" && !config.DryRun {
+			if manifestErr := utils.WriteManifest(config.Manifest, results); manifestErr != nil {
+				log.Printf("\n%sError%s writing manifest %s: %v\n", colors.RedColor, colors.ResetColor, config.Manifest, manifestErr)
+				exitCode = exitFailure
+			}
+		}
+		if config.CSV != "" && !config.DryRun {
+			if csvErr := utils.AppendCSV(config.CSV, results); csvErr != nil {
+				log.Printf("\n%sError%s writing CSV log %s: %v\n", colors.RedColor, colors.ResetColor, config.CSV, csvErr)
+				exitCode = exitFailure
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Successful conversions: %s%d%s, Failed conversions: %s%d%s, Ignored conversions: %s%d%s. %s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, successCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor, spaceChange(utils.SumTimings(results), config))
+		}
+		utils.PrintConversionDetails(results, config)
+	case "list":
+		config.Threads = 1 // Listing is cheap, keep the output in directory order
+		config.OnResult = func(result utils.Result) {
+			printListed(result, config)
+		}
+		results, err := utils.ListFilesContext(ctx, config.Path, config)
+		reportResults, reportErr = results, err
+		eligibleCount, failureCount, ignoredCount := utils.CountResults(results)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Eligible files: %s%d%s, Unreadable files: %s%d%s, Ignored files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, eligibleCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+		}
+	case "count":
+		// The list preflight without per-file output, so huge trees are counted in seconds
+		results, err := utils.ListFilesContext(ctx, config.Path, config)
+		reportResults, reportErr = results, err
+		eligibleCount, failureCount, ignoredCount := utils.CountResults(results)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Eligible: %s%d%s, Ignored: %s%d%s, Unreadable: %s%d%s, Total size: %s%s%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, eligibleCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.GreenColor, utils.FormatSize(utils.SumTimings(results).BytesRead), colors.ResetColor)
+		}
+	case "export", "export-lz4":
+		config.OnResult = func(result utils.Result) {
+			printResult(result, config)
+		}
+		if config.Mode == "export-lz4" {
+			config.Format = "lz4"
+		}
+		results, err := utils.ExportFilesContext(ctx, config.Path, config)
+		reportResults, reportErr = results, err
+		successCount, failureCount, ignoredCount := utils.CountResults(results)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
 			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
 		} else {
-			log.Printf("\n\n%s%s FINISHED%s. Successful conversions: %s%d%s, Failed conversions: %s%d%s, Ignored conversions: %s%d%s\n", colors.GreenColor, strings.ToUpper(config.Mode), colors.ResetColor, colors.GreenColor, successCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Exported files: %s%d%s, Failed files: %s%d%s, Ignored files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, successCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+		}
+	case "raw-extract", "raw-wrap":
+		config.OnResult = func(result utils.Result) {
+			printResult(result, config)
+		}
+		var results []utils.Result
+		var err error
+		if config.Mode == "raw-extract" {
+			results, err = utils.RawExtractFilesContext(ctx, config.Path, config)
+		} else {
+			results, err = utils.RawWrapFilesContext(ctx, config.Path, config)
+		}
+		reportResults, reportErr = results, err
+		successCount, failureCount, ignoredCount := utils.CountResults(results)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Successful files: %s%d%s, Failed files: %s%d%s, Ignored files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, successCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+		}
+	case "pack", "unpack":
+		config.OnResult = func(result utils.Result) {
+			printResult(result, config)
+		}
+		var results []utils.Result
+		var err error
+		if config.Mode == "pack" {
+			results, err = utils.PackDVPLFiles(ctx, config.Path, config.Output, config)
+		} else {
+			results, err = utils.UnpackDVPLFiles(ctx, config.Path, config.Output, config)
+		}
+		reportResults, reportErr = results, err
+		successCount, failureCount, ignoredCount := utils.CountResults(results)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Successful files: %s%d%s, Failed files: %s%d%s, Ignored files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, successCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
 		}
 	case "verify":
-		successCount, failureCount, ignoredCount, err := utils.VerifyDVPLFiles(config.Path, config)
+		config.OnResult = func(result utils.Result) {
+			printResult(result, config)
+		}
+		results, err := utils.VerifyFilesContext(ctx, config.Path, config)
+		reportResults, reportErr = results, err
+		successCount, failureCount, ignoredCount := utils.CountResults(results)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if failureCount > 0 && !config.Quiet {
+			printFailures(results)
+		}
+		if config.QuietOnSuccess && exitCode == exitSuccess {
+			break
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Successful verifications: %s%d%s, Failed verifications: %s%d%s, Ignored files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, successCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+		}
+		if config.Deep && !config.Quiet {
+			fmt.Fprintf(utils.Output, "Files with nested DVPL data: %d\n", utils.CountNested(results))
+		}
+	case "repair":
+		config.OnResult = func(result utils.Result) {
+			printResult(result, config)
+		}
+		results, err := utils.RepairFilesContext(ctx, config.Path, config)
+		reportResults, reportErr = results, err
+		repairedCount, failureCount, ignoredCount := utils.CountResults(results)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Repaired files: %s%d%s, Unrepairable files: %s%d%s, Ignored files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, repairedCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+		}
+	case "compare":
+		stats, err := utils.CompareDVPLFilesContext(ctx, config.Path, config)
+		matchCount, mismatchCount, ignoredCount := stats.Counts()
+		if err != nil || mismatchCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Matching files: %s%d%s, Mismatching files: %s%d%s, Ignored files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, matchCount, colors.ResetColor, colors.RedColor, mismatchCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+		}
+	case "checksum":
+		matchCount, mismatchCount, err := utils.ChecksumManifestContext(ctx, config.Manifest, config)
+		if err != nil || mismatchCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Matching files: %s%d%s, Changed files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, matchCount, colors.ResetColor, colors.RedColor, mismatchCount, colors.ResetColor)
+		}
+	case "benchmark":
+		stats, err := utils.BenchmarkDVPLFiles(ctx, config.Path, config)
+		if err != nil || stats.Failures > 0 {
+			exitCode = exitFailure
+		}
 		if err != nil {
 			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
 		} else {
-			log.Printf("\n\n%s%s FINISHED%s. Successful verifications: %s%d%s, Failed verifications: %s%d%s, Ignored files: %s%d%s\n", colors.GreenColor, strings.ToUpper(config.Mode), colors.ResetColor, colors.GreenColor, successCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+			log.Printf("\n\n%s%s FINISHED%s. Files: %s%d%s, Failed: %s%d%s, Read: %s%s/s%s, Written: %s%s/s%s, Average ratio: %s%.1f%%%s, Per-file time p50/p90/p99: %v/%v/%v\n", colors.GreenColor, strings.ToUpper(config.Mode), colors.ResetColor, colors.GreenColor, stats.Files, colors.ResetColor, colors.RedColor, stats.Failures, colors.ResetColor, colors.GreenColor, utils.FormatSize(int64(stats.InputRate())), colors.ResetColor, colors.GreenColor, utils.FormatSize(int64(stats.OutputRate())), colors.ResetColor, colors.GreenColor, stats.AverageRatio*100, colors.ResetColor, stats.P50, stats.P90, stats.P99)
+		}
+	case "autotune":
+		timings, recommended, err := utils.AutotuneThreads(ctx, config.Path, config)
+		if !config.Quiet {
+			for _, timing := range timings {
+				fmt.Fprintf(utils.Output, "Threads %3d: %s/s, %v\n", timing.Threads, utils.FormatSize(int64(timing.Stats.InputRate())), timing.Stats.Elapsed.Round(time.Millisecond))
+			}
+		}
+		if err != nil {
+			exitCode = exitFailure
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			log.Printf("\n\n%s%s FINISHED%s. Recommended: %s-threads %d%s\n", colors.GreenColor, strings.ToUpper(config.Mode), colors.ResetColor, colors.GreenColor, recommended, colors.ResetColor)
+		}
+	case "info":
+		stats, err := utils.InfoDVPLFilesContext(ctx, config.Path, config)
+		successCount, failureCount, ignoredCount := stats.Counts()
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("\n\n%s%s FAILED%s: %v\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, err)
+		} else {
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Footers read: %s%d%s, Invalid footers: %s%d%s, Ignored files: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, successCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor, colors.YellowColor, ignoredCount, colors.ResetColor)
+		}
+	case "doctor":
+		checks, err := utils.RunDoctorContext(ctx)
+		failureCount := 0
+		for _, check := range checks {
+			if check.Err != nil {
+				failureCount++
+				fmt.Fprintf(utils.Output, "\n%sFAILED%s %s: %v", colors.RedColor, colors.ResetColor, check.Name, check.Err)
+			} else if !config.Quiet {
+				fmt.Fprintf(utils.Output, "\n%sOK%s %s", colors.GreenColor, colors.ResetColor, check.Name)
+			}
+		}
+		if !config.Quiet {
+			environment := utils.Environment()
+			fmt.Fprintf(utils.Output, "\n\nGo version: %s\nOS/arch: %s/%s\nLZ4 library: %s %s\n", environment.GoVersion, environment.OS, environment.Arch, "github.com/pierrec/lz4/v4", environment.LZ4Version)
+		}
+		if err != nil {
+			exitCode = exitFailure
+			statusColor, status := summaryStatus(ctx)
+			log.Printf("\n\n%s%s %s%s. Passed checks: %s%d%s, Failed checks: %s%d%s\n", statusColor, strings.ToUpper(config.Mode), status, colors.ResetColor, colors.GreenColor, len(checks)-failureCount, colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor)
+		} else if failureCount > 0 {
+			exitCode = exitFailure
+			log.Printf("\n\n%s%s FAILED%s. Failed checks: %s%d%s. The codec does not work on this machine, please include this output in a bug report.\n", colors.RedColor, strings.ToUpper(config.Mode), colors.ResetColor, colors.RedColor, failureCount, colors.ResetColor)
+		} else {
+			log.Printf("\n\n%s%s FINISHED%s. Every check passed, the codec works on this machine.\n", colors.GreenColor, strings.ToUpper(config.Mode), colors.ResetColor)
 		}
 	case "gui":
 		runGui() // Call the GUI mode
 	case "help":
 		utils.PrintHelpMessage()
 	default:
-		log.Fatalf("\n\n%sIncorrect mode selected. Use '-help' for information.%s\n\n", colors.RedColor, colors.ResetColor)
+		log.Printf("\n\n%sIncorrect mode selected. Use '-help' for information.%s\n\n", colors.RedColor, colors.ResetColor)
+		stop()
+		os.Exit(exitUsage)
+	}
+
+	if config.Report != "" {
+		if err := writeReport(config.Report, config.Mode, reportResults, reportErr, time.Since(startTime)); err != nil {
+			log.Printf("\n%sError%s writing report %s: %v\n", colors.RedColor, colors.ResetColor, config.Report, err)
+			exitCode = exitFailure
+		}
+	}
+
+	if config.JSONSummary {
+		json.NewEncoder(os.Stdout).Encode(newRunReport(config.Mode, reportResults, reportErr, time.Since(startTime)))
+	}
+
+	if !config.Quiet && !config.QuietOnSuccess {
+		elapsedTime := time.Since(startTime) // Calculate elapsed time
+		utils.PrintElapsedTime(elapsedTime)
+	}
+
+	// The exit code is authoritative for scripts, the summary above is for humans
+	if exitCode != exitSuccess {
+		stop()
+		os.Exit(exitCode)
 	}
+}
 
-	elapsedTime := time.Since(startTime) // Calculate elapsed time
-	utils.PrintElapsedTime(elapsedTime)
+// processPaths converts the files of a -from-file list, or else every path given on the command line.
+func processPaths(ctx context.Context, config *utils.Config) ([]utils.Result, error) {
+	if config.FromFile != "" {
+		return utils.ProcessFileListContext(ctx, config.Paths, config)
+	}
+	return utils.ProcessPathsContext(ctx, config.Paths, config)
+}
+
+// printBanner prints the tool information header.
+func printBanner() {
+	cyan := color.New(color.FgCyan)
+
+	fmt.Fprintln(utils.Output)
+	cyan.Fprintln(utils.Output, "• Name:", meta.Name)
+	cyan.Fprintln(utils.Output, "• Version:", meta.Version)
+	cyan.Fprintln(utils.Output, "• Commit:", meta.Commit)
+	cyan.Fprintln(utils.Output, "• Dev:", meta.Dev)
+	cyan.Fprintln(utils.Output, "• Repo:", meta.Repo)
+	cyan.Fprintln(utils.Output, "• Web:", meta.Web)
+	cyan.Fprintln(utils.Output, "• Info:", meta.Info)
+	fmt.Fprintln(utils.Output)
 }
 
 func runGui() {
 	Gui()
 }
+
+// printResult prints the verbose log line for a single processed file.
+func printResult(result utils.Result, config *utils.Config) {
+	if result.Warn && !config.Quiet {
+		fmt.Fprintf(utils.Output, "\n%sWarning%s: ignoring %s %s\n", colors.YellowColor, colors.ResetColor, result.Reason, result.Path)
+		return
+	}
+	if !config.Verbose {
+		return
+	}
+
+	switch {
+	case result.Ignored() && result.Reason != "":
+		fmt.Fprintf(utils.Output, "\n%sIgnoring%s %s %s\n", colors.YellowColor, colors.ResetColor, result.Reason, result.Path)
+	case result.Ignored():
+		fmt.Fprintf(utils.Output, "\n%sIgnoring%s file %s\n", colors.YellowColor, colors.ResetColor, result.Path)
+	case result.Failed() && result.Action == "repair":
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s %scould not be repaired due to %v%s\n", colors.RedColor, colors.ResetColor, result.Path, colors.RedColor, result.Err, colors.ResetColor)
+	case result.Failed() && result.Action == "verify":
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s %sfailed to verify due to %v%s\n", colors.RedColor, colors.ResetColor, result.Path, colors.RedColor, result.Err, colors.ResetColor)
+	case result.Failed():
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s %sfailed to convert due to %v%s\n", colors.RedColor, colors.ResetColor, result.Path, colors.RedColor, result.Err, colors.ResetColor)
+	case result.Action == "verify" && result.Nesting > 0:
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s has been successfully %sverified%s with %d nested DVPL layers\n", colors.GreenColor, colors.ResetColor, result.Path, colors.GreenColor, colors.ResetColor, result.Nesting)
+	case result.Action == "verify":
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s has been successfully %sverified%s\n", colors.GreenColor, colors.ResetColor, result.Path, colors.GreenColor, colors.ResetColor)
+	case result.Action == "repair" && !config.DryRun:
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s has had its footer %srepaired%s\n", colors.GreenColor, colors.ResetColor, result.Path, colors.GreenColor, colors.ResetColor)
+	case config.DryRun:
+		fmt.Fprintf(utils.Output, "\n%s[DRY RUN]%s would %s %s (%s) -> %s (%s)\n", colors.YellowColor, colors.ResetColor, result.Action, result.Path, utils.FormatSize(result.InputSize), result.OutputPath, utils.FormatSize(result.OutputSize))
+	case result.Action == "compress" || result.Action == "pack":
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s has been successfully %s into %s%s%s %s\n", colors.GreenColor, colors.ResetColor, result.Path, utils.GetAction(result.Action), colors.GreenColor, result.OutputPath, colors.ResetColor, sizeChange(result.InputSize, result.OutputSize))
+	default:
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s has been successfully %s into %s%s%s\n", colors.GreenColor, colors.ResetColor, result.Path, utils.GetAction(result.Action), colors.GreenColor, result.OutputPath, colors.ResetColor)
+	}
+
+	if result.LongPath {
+		fmt.Fprintf(utils.Output, "%sNote%s: %s was accessed with the Windows long path prefix\n", colors.YellowColor, colors.ResetColor, result.Path)
+	}
+
+	if result.RemoveErr != nil {
+		fmt.Fprintf(utils.Output, "\n%sError%s deleting file %s: %v\n", colors.RedColor, colors.ResetColor, result.Path, result.RemoveErr)
+	}
+}
+
+// printFailures lists every failed file at the end of a run, grouped by the kind of failure and sorted by path,
+// so corrupted files do not get lost in the per-file output.
+func printFailures(results []utils.Result) {
+	groups := make(map[string][]utils.Result)
+	for _, result := range results {
+		if result.Failed() {
+			kind := utils.FailureKind(result.Err)
+			groups[kind] = append(groups[kind], result)
+		}
+	}
+
+	fmt.Fprintf(utils.Output, "\n%sFailed files:%s\n", colors.RedColor, colors.ResetColor)
+	for _, kind := range utils.FailureKinds {
+		failed := groups[kind]
+		if len(failed) == 0 {
+			continue
+		}
+		sort.Slice(failed, func(i, j int) bool { return failed[i].Path < failed[j].Path })
+
+		fmt.Fprintf(utils.Output, "\n  %s (%d):\n", kind, len(failed))
+		for _, result := range failed {
+			fmt.Fprintf(utils.Output, "    %s: %v\n", result.Path, result.Err)
+		}
+	}
+}
+
+// summaryStatus returns the color and word that open a summary line: FINISHED, or INTERRUPTED
+// when Ctrl-C stopped the run early and the counts only cover the files handled until then.
+func summaryStatus(ctx context.Context) (string, string) {
+	if ctx.Err() != nil {
+		return colors.YellowColor, "INTERRUPTED"
+	}
+	return colors.GreenColor, "FINISHED"
+}
+
+// spaceChange sums up how the converted files changed in size over the whole run,
+// e.g. "Saved 1.2 GB (63%)" after compressing or "Expanded by 2.0 GB (170%)" after decompressing.
+func spaceChange(timings utils.Timings, config *utils.Config) string {
+	difference := timings.BytesRead - timings.BytesWritten
+	verb := "Saved"
+	if config.DryRun {
+		verb = "Would save"
+	}
+	if difference < 0 {
+		difference = -difference
+		verb = "Expanded by"
+		if config.DryRun {
+			verb = "Would expand by"
+		}
+	}
+
+	percent := int64(0)
+	if timings.BytesRead > 0 {
+		percent = difference * 100 / timings.BytesRead
+	}
+	return fmt.Sprintf("%s %s (%d%%)", verb, utils.FormatSize(difference), percent)
+}
+
+// printListed prints a file found by the list mode. Ignored files are only shown in verbose mode.
+func printListed(result utils.Result, config *utils.Config) {
+	switch {
+	case result.Ignored():
+		printResult(result, config)
+	case result.Failed():
+		fmt.Fprintf(utils.Output, "\n%sFile%s %s %scould not be listed due to %v%s\n", colors.RedColor, colors.ResetColor, result.Path, colors.RedColor, result.Err, colors.ResetColor)
+	case !config.Quiet:
+		fmt.Fprintf(utils.Output, "\n%s%s%s %s (%s)\n", colors.GreenColor, result.Action, colors.ResetColor, result.Path, utils.FormatSize(result.InputSize))
+	}
+}
+
+// sizeChange describes how much a file shrank, e.g. "(1.0 MB -> 320 KB, 68% smaller)".
+func sizeChange(inputSize, outputSize int64) string {
+	sizes := utils.FormatSize(inputSize) + " -> " + utils.FormatSize(outputSize)
+	if inputSize == 0 {
+		return "(" + sizes + ")"
+	}
+	if outputSize > inputSize {
+		return fmt.Sprintf("(%s, %d%% larger)", sizes, (outputSize-inputSize)*100/inputSize)
+	}
+	return fmt.Sprintf("(%s, %d%% smaller)", sizes, (inputSize-outputSize)*100/inputSize)
+}
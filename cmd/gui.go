@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"context"
 	"embed"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -21,6 +26,14 @@ import (
 //go:embed resource/dvpl_lz4.png
 var resources embed.FS
 
+// Preference keys under which the GUI remembers its last-used settings between sessions.
+const (
+	prefPath          = "path"
+	prefKeepOriginals = "keepOriginals"
+	prefIgnore        = "ignore"
+	prefIgnoreExt     = "ignoreExtensions"
+)
+
 func Gui() {
 	myApp := app.NewWithID("xyz.rxd.dvpl_lz4")
 	myWindow := myApp.NewWindow("DVPL_LZ4 GUI CONVERTER")
@@ -30,11 +43,25 @@ func Gui() {
 	iconResource := fyne.NewStaticResource("dvpl_lz4.png", iconData)
 	myWindow.SetIcon(iconResource)
 
-	config := &utils.Config{}
+	config := &utils.Config{Overwrite: true, PreserveTimes: true}
+	prefs := myApp.Preferences()
 
 	// Parse command-line arguments
 	flag.Parse()
 
+	// A path given on the command line wins over the one remembered from the last session
+	pathGiven := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "path" {
+			pathGiven = true
+		}
+	})
+	if !pathGiven {
+		if lastPath := prefs.String(prefPath); lastPath != "" {
+			utils.GlobalPath = lastPath
+		}
+	}
+
 	// Check if the GlobalPath variable is empty
 	if utils.GlobalPath == "" {
 		// If it is, get the current directory
@@ -44,45 +71,84 @@ func Gui() {
 	}
 
 	// Set the path to the value of the global variable
-	config.Path = utils.GlobalPath
+	setPaths(config, utils.GlobalPath)
+
+	// Progress of the running conversion, hidden while idle
+	progressBar := widget.NewProgressBar()
+	progressBar.Hide()
+
+	// Every file of the last run, listed as it completes
+	runLog := newLogPanel()
+
+	var compressButton, decompressButton *widget.Button
+	setBusy := func(busy bool) {
+		for _, button := range []*widget.Button{compressButton, decompressButton} {
+			if busy {
+				button.Disable()
+			} else {
+				button.Enable()
+			}
+		}
+	}
 
-	compressButton := widget.NewButton("Compress", func() {
+	compressButton = widget.NewButton("Compress", func() {
 		config.Mode = "compress"
-		convertFiles(myWindow, config) // Pass myWindow as a parameter
+		convertFiles(myWindow, config, progressBar, runLog, setBusy) // Pass myWindow as a parameter
 	})
 
-	decompressButton := widget.NewButton("Decompress", func() {
+	decompressButton = widget.NewButton("Decompress", func() {
 		config.Mode = "decompress"
-		convertFiles(myWindow, config) // Pass myWindow as a parameter
+		convertFiles(myWindow, config, progressBar, runLog, setBusy) // Pass myWindow as a parameter
 	})
 
+	// Every option is saved as it changes and restored from the last session. Originals are kept
+	// until the user unchecks the box, so a first click on Compress never deletes anything.
 	keepOriginalsCheck := widget.NewCheck("Keep Originals", func(keep bool) {
 		config.KeepOriginals = keep
+		prefs.SetBool(prefKeepOriginals, keep)
 	})
+	keepOriginalsCheck.SetChecked(prefs.BoolWithFallback(prefKeepOriginals, true))
 
 	ignoreCheck := widget.NewCheck("Ignore Extensions", func(ignore bool) {
 		config.IgnoreExt = ignore
+		prefs.SetBool(prefIgnoreExt, ignore)
 	})
+	ignoreCheck.SetChecked(prefs.Bool(prefIgnoreExt))
 
 	ignoreEntry := widget.NewEntry()
 	ignoreEntry.SetPlaceHolder("Enter comma-separated extensions to ignore")
 	ignoreEntry.OnChanged = func(ext string) {
 		config.Ignore = ext
+		prefs.SetString(prefIgnore, ext)
 	}
+	ignoreEntry.SetText(prefs.String(prefIgnore))
 
 	pathEntry := widget.NewEntry()
 	pathEntry.SetText(utils.GlobalPath) // Set the text to the value of the global variable
-	pathEntry.SetPlaceHolder("Enter directory or file path")
+	pathEntry.SetPlaceHolder("Enter or drop directory or file paths")
 	pathEntry.OnChanged = func(path string) {
-		config.Path = path
+		setPaths(config, path)
+		prefs.SetString(prefPath, path)
 	}
 
+	// Dropped files and folders replace the path, several of them are processed together
+	myWindow.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+		var paths []string
+		for _, uri := range uris {
+			if uri.Scheme() == "file" {
+				paths = append(paths, uri.Path())
+			}
+		}
+		if len(paths) > 0 {
+			pathEntry.SetText(strings.Join(paths, string(os.PathListSeparator)))
+		}
+	})
+
 	// Button to select a directory
 	selectFolderButton := widget.NewButton("Select Directory", func() {
 		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
 			if uri != nil {
-				pathEntry.SetText(uri.Path()) // Set the selected path in the text entry
-				config.Path = uri.Path()      // Update the config with the selected path
+				pathEntry.SetText(uri.Path()) // Set the selected path in the text entry, which updates the config
 			}
 		}, myWindow)
 	})
@@ -91,13 +157,15 @@ func Gui() {
 	verifyButton := widget.NewButton("Verify", func() {
 		config := &utils.Config{
 			Mode: "verify",
-			Path: pathEntry.Text,
 			// Set other configuration options as needed
 		}
+		setPaths(config, pathEntry.Text)
+		runLog.clear()
+		config.OnResult = runLog.add
 		verifyFiles(myWindow, config) // Call the verifyFiles function
 	})
 
-	content := container.NewVBox(
+	controls := container.NewVBox(
 		widget.NewLabelWithStyle("DVPL_LZ4 GUI TOOL • "+meta.Version, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
 		container.NewHBox(layout.NewSpacer(), compressButton, decompressButton, verifyButton, layout.NewSpacer()),
 		widget.NewForm(
@@ -107,44 +175,272 @@ func Gui() {
 			widget.NewFormItem("Path:", pathEntry),
 		),
 		selectFolderButton, // Add the "Select Directory" button to the UI
+		progressBar,
 	)
 
-	myWindow.SetContent(content)
-	myWindow.Resize(fyne.NewSize(500, 200))
+	// The log takes whatever height the controls leave
+	myWindow.SetContent(container.NewBorder(controls, nil, nil, nil, runLog.list))
+	myWindow.Resize(fyne.NewSize(700, 500))
 	myWindow.ShowAndRun()
+}
+
+// maxLogLines is how many lines the log panel keeps, dropping the oldest beyond it.
+const maxLogLines = 10000
 
-	// Add the "Verify" button to the content
-	content.Add(verifyButton)
+// logLine is one result shown in the log panel.
+type logLine struct {
+	text       string
+	importance widget.Importance
 }
 
-func convertFiles(myWindow fyne.Window, config *utils.Config) {
-	startTime := time.Now() // Record start time
+// logPanel is the scrolling console under the controls listing every file of a run as it is handled,
+// colored by status, so failed conversions can be read without a terminal.
+type logPanel struct {
+	list *widget.List
+
+	mu    sync.Mutex
+	lines []logLine
+}
+
+// newLogPanel returns an empty log panel.
+func newLogPanel() *logPanel {
+	panel := &logPanel{}
+	panel.list = widget.NewList(
+		func() int {
+			panel.mu.Lock()
+			defer panel.mu.Unlock()
+			return len(panel.lines)
+		},
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.ListItemID, item fyne.CanvasObject) {
+			panel.mu.Lock()
+			line := panel.lines[id]
+			panel.mu.Unlock()
+
+			label := item.(*widget.Label)
+			label.Importance = line.importance
+			label.SetText(line.text)
+		},
+	)
+	return panel
+}
+
+// add appends a result to the log and scrolls to it. It is safe to call from any goroutine.
+func (panel *logPanel) add(result utils.Result) {
+	panel.mu.Lock()
+	panel.lines = append(panel.lines, newLogLine(result))
+	if len(panel.lines) > maxLogLines {
+		panel.lines = panel.lines[len(panel.lines)-maxLogLines:]
+	}
+	panel.mu.Unlock()
+
+	panel.list.Refresh()
+	panel.list.ScrollToBottom()
+}
 
-	successCount, failureCount, ignoredCount, err := utils.ProcessFiles(config.Path, config)
-	if err != nil {
-		dialog.NewError(err, myWindow)
+// clear empties the log before a new run.
+func (panel *logPanel) clear() {
+	panel.mu.Lock()
+	panel.lines = nil
+	panel.mu.Unlock()
+
+	panel.list.Refresh()
+}
+
+// newLogLine describes a result the way the command-line log does, on a single line.
+func newLogLine(result utils.Result) logLine {
+	switch {
+	case result.Failed():
+		return logLine{fmt.Sprintf("Failed %s: %v", result.Path, result.Err), widget.DangerImportance}
+	case result.Warn:
+		return logLine{fmt.Sprintf("Warning: ignoring %s %s", result.Reason, result.Path), widget.WarningImportance}
+	case result.Ignored() && result.Reason != "":
+		return logLine{fmt.Sprintf("Ignoring %s %s", result.Reason, result.Path), widget.LowImportance}
+	case result.Ignored():
+		return logLine{"Ignoring file " + result.Path, widget.LowImportance}
+	case result.OutputPath == "":
+		return logLine{fmt.Sprintf("OK %s %s", result.Action, result.Path), widget.SuccessImportance}
+	}
+	return logLine{fmt.Sprintf("OK %s %s -> %s", result.Action, result.Path, result.OutputPath), widget.SuccessImportance}
+}
+
+// setPaths fills config.Paths from the path entry text, where several paths are separated
+// by the OS path list separator, and config.Path from the first of them.
+func setPaths(config *utils.Config, text string) {
+	config.Paths = nil
+	for _, path := range filepath.SplitList(text) {
+		if path = strings.TrimSpace(path); path != "" {
+			config.Paths = append(config.Paths, path)
+		}
+	}
+
+	config.Path = ""
+	if len(config.Paths) > 0 {
+		config.Path = config.Paths[0]
+	}
+}
+
+// convertFiles runs the conversion in the background so the window stays responsive, advancing
+// progressBar as files complete and showing the results dialog once every file is done.
+// setBusy disables the conversion buttons for the duration of the run. A run that would delete
+// originals only starts once the user confirms how many files it removes.
+func convertFiles(myWindow fyne.Window, config *utils.Config, progressBar *widget.ProgressBar, runLog *logPanel, setBusy func(bool)) {
+	// Work on a copy so edits in the window cannot change a running conversion
+	runConfig := *config
+
+	// Listing is cheap and walks the same files, so it gives the bar its maximum up front
+	// and tells how many originals the run deletes
+	listConfig := runConfig
+	listConfig.OnResult = nil
+	total, deleteCount := 0, 0
+	for _, path := range runConfig.Paths {
+		listed, _ := utils.ListFilesContext(context.Background(), path, &listConfig)
+		total += len(listed)
+		for _, result := range listed {
+			if result.Action == runConfig.Mode {
+				deleteCount++
+			}
+		}
+	}
+
+	if runConfig.KeepOriginals || runConfig.Output != "" || deleteCount == 0 {
+		runConversion(myWindow, &runConfig, total, progressBar, runLog, setBusy)
 		return
 	}
+	message := fmt.Sprintf("%d original files will be deleted once they are %s.\nCheck \"Keep Originals\" to keep them.\n\nContinue?", deleteCount, runConfig.Mode+"ed")
+	dialog.ShowConfirm("Delete Originals?", message, func(confirmed bool) {
+		if confirmed {
+			runConversion(myWindow, &runConfig, total, progressBar, runLog, setBusy)
+		}
+	}, myWindow)
+}
 
-	elapsedTime := time.Since(startTime) // Calculate elapsed time
+// runConversion converts the files of runConfig in the background, for convertFiles. total is the
+// number of results the run is expected to report, which the progress bar counts up to.
+func runConversion(myWindow fyne.Window, runConfig *utils.Config, total int, progressBar *widget.ProgressBar, runLog *logPanel, setBusy func(bool)) {
+	startTime := time.Now() // Record start time
+
+	runLog.clear()
+	var completed int64
+	runConfig.OnResult = func(result utils.Result) {
+		// Progress bar and log updates are safe from any goroutine
+		progressBar.SetValue(float64(atomic.AddInt64(&completed, 1)))
+		runLog.add(result)
+	}
 
-	successContent := fmt.Sprintf("Successful conversions: %d\nFailed conversions: %d\nIgnored conversions: %d\n\nTime taken: %s", successCount, failureCount, ignoredCount, formatElapsedTime(elapsedTime))
-	successDialog := dialog.NewInformation("Conversion Results", successContent, myWindow)
-	successDialog.SetDismissText("OK")
+	progressBar.Min = 0
+	progressBar.Max = float64(total)
+	progressBar.SetValue(0)
+	progressBar.Show()
+	setBusy(true)
 
-	successDialog.Show()
+	go func() {
+		results, err := utils.ProcessPathsContext(context.Background(), runConfig.Paths, runConfig)
+
+		progressBar.Hide()
+		setBusy(false)
+
+		if err != nil {
+			dialog.NewError(err, myWindow).Show()
+			return
+		}
+		successCount, failureCount, ignoredCount := utils.CountResults(results)
+
+		elapsedTime := time.Since(startTime) // Calculate elapsed time
+
+		successContent := fmt.Sprintf("Successful conversions: %d, Failed conversions: %d, Ignored conversions: %d. Time taken: %s", successCount, failureCount, ignoredCount, formatElapsedTime(elapsedTime))
+		resultsDialog := dialog.NewCustom("Conversion Results", "OK", resultsTable(successContent, results), myWindow)
+		resultsDialog.Resize(fyne.NewSize(800, 500))
+		resultsDialog.Show()
+	}()
+}
+
+// resultColumns are the headers of the results table, one column per resultCell field.
+var resultColumns = []string{"File", "Action", "Input", "Output", "Status"}
+
+// resultsTable lists every result below the summary line, with a check that narrows the table to the failures.
+func resultsTable(summary string, results []utils.Result) fyne.CanvasObject {
+	shown := results
+
+	table := widget.NewTable(
+		func() (int, int) { return len(shown), len(resultColumns) },
+		func() fyne.CanvasObject { return widget.NewLabel("") },
+		func(id widget.TableCellID, cell fyne.CanvasObject) {
+			cell.(*widget.Label).SetText(resultCell(shown[id.Row], id.Col))
+		},
+	)
+	table.ShowHeaderRow = true
+	table.CreateHeader = func() fyne.CanvasObject { return widget.NewLabel("") }
+	table.UpdateHeader = func(id widget.TableCellID, header fyne.CanvasObject) {
+		if id.Col >= 0 {
+			header.(*widget.Label).SetText(resultColumns[id.Col])
+		}
+	}
+	for col, width := range []float32{360, 90, 80, 80, 300} {
+		table.SetColumnWidth(col, width)
+	}
+
+	failuresOnly := widget.NewCheck("Show only failures", func(only bool) {
+		shown = results
+		if only {
+			shown = nil
+			for _, result := range results {
+				if result.Failed() {
+					shown = append(shown, result)
+				}
+			}
+		}
+		table.Refresh()
+	})
+
+	return container.NewBorder(container.NewVBox(widget.NewLabel(summary), failuresOnly), nil, nil, nil, table)
+}
+
+// resultCell formats one column of a result for the results table.
+func resultCell(result utils.Result, col int) string {
+	switch col {
+	case 0:
+		return result.Path
+	case 1:
+		return result.Action
+	case 2:
+		if result.Ignored() {
+			return ""
+		}
+		return utils.FormatSize(result.InputSize)
+	case 3:
+		if result.Ignored() || result.Failed() {
+			return ""
+		}
+		return utils.FormatSize(result.OutputSize)
+	}
+
+	switch {
+	case result.Failed():
+		return "Failed: " + result.Err.Error()
+	case result.Ignored() && result.Reason != "":
+		return "Ignored: " + result.Reason
+	case result.Ignored():
+		return "Ignored"
+	}
+	return "OK"
 }
 
 func verifyFiles(myWindow fyne.Window, config *utils.Config) {
 	startTime := time.Now() // Record start time
 
-	// Call the verification function with the provided configuration
-	successCount, failureCount, ignoredCount, err := utils.VerifyDVPLFiles(config.Path, config)
-	if err != nil {
-		// Display an error dialog if verification fails
-		dialog.NewError(err, myWindow).Show()
-		return
+	// Call the verification function on every path with the provided configuration
+	var total utils.Stats
+	for _, path := range config.Paths {
+		stats, err := utils.VerifyDVPLFiles(path, config)
+		if err != nil {
+			// Display an error dialog if verification fails
+			dialog.NewError(err, myWindow).Show()
+			return
+		}
+		total.Add(&stats)
 	}
+	successCount, failureCount, ignoredCount := total.Counts()
 
 	// Calculate elapsed time
 	elapsedTime := time.Since(startTime)
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/rifsxd/dvpl_lz4/common/utils"
+)
+
+// jsonResult is the machine-readable form of a single processed file.
+type jsonResult struct {
+	Path        string `json:"path"`
+	Output      string `json:"output,omitempty"`
+	Action      string `json:"action"`
+	In          int64  `json:"in"`
+	Out         int64  `json:"out"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	RemoveError string `json:"remove_error,omitempty"`
+}
+
+// jsonSummary is the final object emitted after every file has been processed.
+type jsonSummary struct {
+	Summary   bool   `json:"summary"`
+	Mode      string `json:"mode"`
+	Success   int    `json:"success"`
+	Failure   int    `json:"failure"`
+	Ignored   int    `json:"ignored"`
+	In        int64  `json:"in"`  // Bytes read from the converted files
+	Out       int64  `json:"out"` // Bytes written for the converted files
+	ElapsedMS int64  `json:"elapsed_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+func newJSONResult(result utils.Result) jsonResult {
+	out := jsonResult{
+		Path:   result.Path,
+		Output: result.OutputPath,
+		Action: result.Action,
+		In:     result.InputSize,
+		Out:    result.OutputSize,
+		Status: "ok",
+	}
+
+	switch {
+	case result.Failed():
+		out.Status = "error"
+		out.Error = result.Err.Error()
+	case result.Ignored():
+		out.Status = "ignored"
+	}
+
+	if result.RemoveErr != nil {
+		out.RemoveError = result.RemoveErr.Error()
+	}
+	return out
+}
+
+// runJSON processes files and writes one JSON object per file plus a summary object to stdout.
+// It reports whether every file was processed without failure.
+func runJSON(ctx context.Context, config *utils.Config, startTime time.Time) bool {
+	encoder := json.NewEncoder(os.Stdout)
+
+	config.OnResult = func(result utils.Result) {
+		encoder.Encode(newJSONResult(result))
+	}
+	results, err := processPaths(ctx, config)
+
+	summary := jsonSummary{Summary: true, Mode: config.Mode}
+	summary.Success, summary.Failure, summary.Ignored = utils.CountResults(results)
+	timings := utils.SumTimings(results)
+	summary.In, summary.Out = timings.BytesRead, timings.BytesWritten
+	summary.ElapsedMS = time.Since(startTime).Milliseconds()
+	if config.Manifest != "" && !config.DryRun {
+		if manifestErr := utils.WriteManifest(config.Manifest, results); manifestErr != nil && err == nil {
+			err = manifestErr
+		}
+	}
+	if config.CSV != "" && !config.DryRun {
+		if csvErr := utils.AppendCSV(config.CSV, results); csvErr != nil && err == nil {
+			err = csvErr
+		}
+	}
+	if config.Report != "" {
+		if reportErr := writeReport(config.Report, config.Mode, results, err, time.Since(startTime)); reportErr != nil && err == nil {
+			err = reportErr
+		}
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+	encoder.Encode(summary)
+
+	return err == nil && summary.Failure == 0
+}
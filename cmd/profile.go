@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+
+	"github.com/rifsxd/dvpl_lz4/common/colors"
+	"github.com/rifsxd/dvpl_lz4/common/utils"
+)
+
+// startProfiles starts the CPU profile requested with -cpuprofile. The returned function stops it and
+// writes the -memprofile heap profile; it must run before the process exits and only acts once.
+func startProfiles(config *utils.Config) (func(), error) {
+	var cpuFile *os.File
+	if config.CPUProfile != "" {
+		var err error
+		if cpuFile, err = os.Create(config.CPUProfile); err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("starting CPU profile: %w", err)
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if cpuFile != nil {
+				pprof.StopCPUProfile()
+				cpuFile.Close()
+			}
+			if config.MemProfile != "" {
+				if err := writeHeapProfile(config.MemProfile); err != nil {
+					log.Printf("\n%sError%s writing heap profile: %v\n", colors.RedColor, colors.ResetColor, err)
+				}
+			}
+		})
+	}, nil
+}
+
+// writeHeapProfile writes a heap profile of the live and allocated memory to path.
+func writeHeapProfile(path string) error {
+	memFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer memFile.Close()
+
+	// Collect garbage first so the profile shows what is still in use
+	runtime.GC()
+	return pprof.WriteHeapProfile(memFile)
+}
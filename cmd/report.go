@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rifsxd/dvpl_lz4/common/utils"
+)
+
+// runReport is the JSON document -report writes once a run is over, for scripts wrapping the tool.
+type runReport struct {
+	Mode         string          `json:"mode"`
+	Success      int             `json:"success"`
+	Failure      int             `json:"failure"`
+	Ignored      int             `json:"ignored"`
+	In           int64           `json:"in"`  // Bytes read from the converted files
+	Out          int64           `json:"out"` // Bytes written for the converted files
+	ElapsedMS    int64           `json:"elapsed_ms"`
+	Error        string          `json:"error,omitempty"` // Why the run itself failed or stopped early
+	FailureKinds map[string]int  `json:"failure_kinds"`   // Failed files per utils.FailureKind
+	Failed       []reportFailure `json:"failed"`
+}
+
+// reportFailure is a failed file in a runReport.
+type reportFailure struct {
+	Path  string `json:"path"`
+	Kind  string `json:"kind"`
+	Error string `json:"error"`
+}
+
+// writeReport writes the outcome of a run to path as a runReport.
+func writeReport(path, mode string, results []utils.Result, runErr error, elapsed time.Duration) error {
+	data, err := json.MarshalIndent(newRunReport(mode, results, runErr, elapsed), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// newRunReport sums up the outcome of a run, with the failed files sorted by path.
+func newRunReport(mode string, results []utils.Result, runErr error, elapsed time.Duration) runReport {
+	report := runReport{
+		Mode:         mode,
+		ElapsedMS:    elapsed.Milliseconds(),
+		FailureKinds: make(map[string]int),
+		Failed:       []reportFailure{},
+	}
+	report.Success, report.Failure, report.Ignored = utils.CountResults(results)
+	timings := utils.SumTimings(results)
+	report.In, report.Out = timings.BytesRead, timings.BytesWritten
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+
+	for _, result := range results {
+		if !result.Failed() {
+			continue
+		}
+		kind := utils.FailureKind(result.Err)
+		report.FailureKinds[kind]++
+		report.Failed = append(report.Failed, reportFailure{Path: result.Path, Kind: kind, Error: result.Err.Error()})
+	}
+	sort.Slice(report.Failed, func(i, j int) bool {
+		return report.Failed[i].Path < report.Failed[j].Path
+	})
+	return report
+}
@@ -1,9 +1,93 @@
 package colors
 
-// ANSI escape codes for text coloring
-const (
+import (
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mattn/go-isatty"
+)
+
+// ANSI escape codes for text coloring, empty while colors are disabled
+var (
 	RedColor    = "\033[31m"
 	GreenColor  = "\033[32m"
 	YellowColor = "\033[33m"
 	ResetColor  = "\033[0m"
 )
+
+// enabled reports whether the escape codes above are set.
+var enabled = true
+
+// Colors start disabled when NO_COLOR is set (https://no-color.org) or stdout is not a terminal.
+func init() {
+	SetEnabled(os.Getenv("NO_COLOR") == "" && IsTerminal(os.Stdout))
+}
+
+// Enabled reports whether colored output is currently on.
+func Enabled() bool {
+	return enabled
+}
+
+// SetEnabled turns colored output on or off for everything printed through this package,
+// including the github.com/fatih/color output used for the banner.
+func SetEnabled(on bool) {
+	enabled = on
+	if on {
+		RedColor, GreenColor, YellowColor, ResetColor = "\033[31m", "\033[32m", "\033[33m", "\033[0m"
+	} else {
+		RedColor, GreenColor, YellowColor, ResetColor = "", "", "", ""
+	}
+	color.NoColor = !on
+}
+
+// IsTerminal reports whether w writes to a terminal.
+func IsTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd())
+}
+
+// NewStripWriter returns a writer that removes ANSI escape sequences before writing to w.
+func NewStripWriter(w io.Writer) io.Writer {
+	return &stripWriter{w: w}
+}
+
+// stripWriter drops ANSI escape sequences, which may be split across writes.
+type stripWriter struct {
+	w     io.Writer
+	state int // 0 in text, 1 after ESC, 2 inside a CSI sequence
+}
+
+func (s *stripWriter) Write(p []byte) (int, error) {
+	text := make([]byte, 0, len(p))
+	for _, b := range p {
+		switch s.state {
+		case 0:
+			if b == 0x1b {
+				s.state = 1
+				continue
+			}
+			text = append(text, b)
+		case 1:
+			// Only CSI sequences are used, anything else ends the escape
+			if b == '[' {
+				s.state = 2
+			} else {
+				s.state = 0
+			}
+		case 2:
+			// CSI sequences end with a byte in the 0x40-0x7e range
+			if b >= 0x40 && b <= 0x7e {
+				s.state = 0
+			}
+		}
+	}
+
+	if _, err := s.w.Write(text); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,108 @@
+package dvpl
+
+import "encoding/binary"
+
+// MaxAcceleration is the highest acceleration accepted by CompressDVPLAcceleration, the same limit as
+// the C library's LZ4_compress_fast.
+const MaxAcceleration = 65537
+
+// Limits of the LZ4 block format: the last 5 bytes are always literals, the last match starts at least
+// 12 bytes before the end of the block, and matches are at least 4 bytes long and at most 64 KiB back.
+const (
+	lz4LastLiterals = 5
+	lz4MatchLimit   = 12
+	lz4MinMatch     = 4
+	lz4MaxOffset    = 65535
+)
+
+// Hash table size of the accelerated compressor, 4096 entries like the C library's default.
+const (
+	fastHashLog  = 12
+	fastSkipLog  = 6 // Misses before the search step grows by one, scaled by the acceleration
+	fastHashSeed = 2654435761
+)
+
+// compressBlockFast compresses src into dst as a raw LZ4 block the way LZ4_compress_fast does and returns
+// the compressed size. dst must hold at least lz4.CompressBlockBound(len(src)) bytes.
+//
+// github.com/pierrec/lz4/v4 has no acceleration setting, so this is used for accelerations above 1. Every
+// acceleration step makes the match search skip ahead faster through data that does not match, which
+// trades compression ratio for speed. Acceleration 1 is left to the library's compressor.
+func compressBlockFast(src, dst []byte, acceleration int) int {
+	var table [1 << fastHashLog]int // Last position+1 of each hashed 4-byte sequence, 0 when unused
+	hash := func(pos int) uint32 {
+		return binary.LittleEndian.Uint32(src[pos:]) * fastHashSeed >> (32 - fastHashLog)
+	}
+
+	out := dst[:0]
+	anchor := 0
+	if len(src) > lz4MatchLimit {
+		matchEnd := len(src) - lz4LastLiterals
+		lastStart := len(src) - lz4MatchLimit
+		table[hash(0)] = 1
+
+		for pos := 1; pos <= lastStart; {
+			// Search for a match, stepping further ahead the longer nothing matches
+			ref, found := 0, false
+			for misses := acceleration << fastSkipLog; pos <= lastStart; misses++ {
+				h := hash(pos)
+				ref = table[h] - 1
+				table[h] = pos + 1
+				if ref >= 0 && pos-ref <= lz4MaxOffset &&
+					binary.LittleEndian.Uint32(src[ref:]) == binary.LittleEndian.Uint32(src[pos:]) {
+					found = true
+					break
+				}
+				pos += misses >> fastSkipLog
+			}
+			if !found {
+				break
+			}
+
+			// Extend the match backwards into the pending literals and forwards as far as allowed
+			for pos > anchor && ref > 0 && src[pos-1] == src[ref-1] {
+				pos--
+				ref--
+			}
+			length := lz4MinMatch
+			for pos+length+8 <= matchEnd &&
+				binary.LittleEndian.Uint64(src[pos+length:]) == binary.LittleEndian.Uint64(src[ref+length:]) {
+				length += 8
+			}
+			for pos+length < matchEnd && src[pos+length] == src[ref+length] {
+				length++
+			}
+
+			out = appendSequence(out, src[anchor:pos], pos-ref, length)
+			pos += length
+			anchor = pos
+			if pos <= lastStart {
+				table[hash(pos-2)] = pos - 1
+			}
+		}
+	}
+
+	// The block ends with the remaining input as literals
+	out = appendSequenceHeader(out, len(src)-anchor, 0)
+	out = append(out, src[anchor:]...)
+	return len(out)
+}
+
+// appendSequence appends an LZ4 sequence of literals followed by a match of length bytes offset bytes back.
+func appendSequence(b, literals []byte, offset, length int) []byte {
+	length -= lz4MinMatch
+	nibble := byte(0xF)
+	if length < 0xF {
+		nibble = byte(length)
+	}
+	b = appendSequenceHeader(b, len(literals), nibble)
+	b = append(b, literals...)
+	b = append(b, byte(offset), byte(offset>>8))
+	if length >= 0xF {
+		for length -= 0xF; length >= 0xFF; length -= 0xFF {
+			b = append(b, 0xFF)
+		}
+		b = append(b, byte(length))
+	}
+	return b
+}
@@ -1,53 +1,140 @@
 package dvpl
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"hash/crc32"
+	"io"
+	"os"
 
 	"github.com/pierrec/lz4/v4"
-	"github.com/rifsxd/dvpl_lz4/common/colors"
 )
 
-// Constants related to DVPL format
+// Sizes of the DVPL footers in bytes, and the compression types a footer can record.
 const (
-	dvplFooterSize = 20
-	dvplTypeNone   = 0
-	dvplTypeLZ4    = 2
-	dvplFooter     = "DVPL"
+	FooterSize   = 20 // Version 1 footer, the one WoTB reads
+	FooterV2Size = 24 // Version 2 footer, with the CRC32 of the original data
+	TypeNone     = 0  // Block stored uncompressed
+	TypeLZ4      = 2  // Block compressed as a raw LZ4 block
 )
 
-// DVPLFooter represents the footer structure of a DVPL file
+// Magic bytes ending version 1 and version 2 footers.
+const (
+	dvplFooter   = "DVPL"
+	dvplFooterV2 = "DVP2"
+)
+
+// MaxLevel is the highest compression level accepted by CompressDVPLLevel.
+const MaxLevel = 12
+
+// MaxPadding is the largest amount of trailing padding TrimDVPLPadding looks past for a footer.
+const MaxPadding = 4096
+
+// MaxRatio is the largest original-to-compressed size ratio accepted for an LZ4 block. LZ4 cannot expand
+// data by more than about 255 times, so a footer claiming more is corrupt and is rejected before its
+// OriginalSize is allocated.
+var MaxRatio uint64 = 255
+
+// Errors returned by the DVPL codec. Callers can match them with errors.Is.
+var (
+	ErrInvalidFooter = errors.New("InvalidDVPLFooter")
+	ErrSizeMismatch  = errors.New("DVPLSizeMismatch")
+	ErrCRC32Mismatch = errors.New("DVPLCRC32Mismatch")
+	ErrUnknownType   = errors.New("UNKNOWN DVPL FORMAT")
+
+	// ErrUnrepairable is returned by RepairDVPL for blocks that cannot be decoded whatever the footer says.
+	ErrUnrepairable = errors.New("DVPLUnrepairable")
+
+	// ErrFileTooSmall is returned for data too short to hold a footer, such as a truncated download.
+	// It is reported together with ErrInvalidFooter, so either can be matched.
+	ErrFileTooSmall = errors.New("DVPLFileTooSmall")
+)
+
+// errCorruptLZ4Block is returned by ValidateDVPL for LZ4 sequences that cannot be decoded.
+var errCorruptLZ4Block = fmt.Errorf("%w: corrupt LZ4 block", ErrSizeMismatch)
+
+// DVPLFooter represents the footer structure of a DVPL file.
+//
+// Version 1 is the 20-byte footer WoTB reads. Version 2 footers end in "DVP2" instead of "DVPL" and are
+// preceded by 4 more bytes holding the CRC32 of the original data, so a bad decode is caught as well as
+// a damaged block. The game cannot read version 2 files.
 type DVPLFooter struct {
 	OriginalSize   uint32 // Original size of the data
 	CompressedSize uint32 // Compressed size of the data
 	CRC32          uint32 // CRC32 checksum of the data
-	Type           uint32 // Type of compression used (0 - None, 2 - LZ4)
+	Type           uint32 // Type of compression used, TypeNone or TypeLZ4
+	Version        int    // Footer format version, 1 or 2
+	OriginalCRC32  uint32 // CRC32 checksum of the original data, version 2 only
+}
+
+// ParseFooter reads the version 1 or 2 footer at the end of a DVPL buffer. Only the magic is checked;
+// whether the sizes, type and checksums match the block is left to the caller.
+func ParseFooter(buffer []byte) (*DVPLFooter, error) {
+	return readDVPLFooter(buffer)
+}
+
+// Bytes encodes the footer as it is stored after the block: FooterSize bytes for version 1, or FooterV2Size
+// bytes starting with OriginalCRC32 for version 2.
+func (footer *DVPLFooter) Bytes() []byte {
+	result := make([]byte, footer.size())
+	start := 0
+	if footer.Version == 2 {
+		writeLittleEndianUint32(result, footer.OriginalCRC32, 0)
+		start = FooterV2Size - FooterSize
+	}
+	putDVPLFooter(result[start:], footer.OriginalSize, footer.CompressedSize, footer.CRC32, footer.Type)
+	if footer.Version == 2 {
+		copy(result[len(result)-len(dvplFooterV2):], dvplFooterV2)
+	}
+	return result
+}
+
+// size returns the length of the footer in bytes.
+func (footer *DVPLFooter) size() int {
+	if footer.Version == 2 {
+		return FooterV2Size
+	}
+	return FooterSize
 }
 
 // createDVPLFooter creates a DVPL footer from the provided data.
 func createDVPLFooter(inputSize, compressedSize, crc32, typeVal uint32) []byte {
-	result := make([]byte, dvplFooterSize)
-	writeLittleEndianUint32(result, inputSize, 0)
-	writeLittleEndianUint32(result, compressedSize, 4)
-	writeLittleEndianUint32(result, crc32, 8)
-	writeLittleEndianUint32(result, typeVal, 12)
-	copy(result[16:], dvplFooter)
+	result := make([]byte, FooterSize)
+	putDVPLFooter(result, inputSize, compressedSize, crc32, typeVal)
 	return result
 }
 
-// readDVPLFooter reads the DVPL footer data from a DVPL buffer.
+// putDVPLFooter writes a DVPL footer into the first FooterSize bytes of b.
+func putDVPLFooter(b []byte, inputSize, compressedSize, crc32, typeVal uint32) {
+	writeLittleEndianUint32(b, inputSize, 0)
+	writeLittleEndianUint32(b, compressedSize, 4)
+	writeLittleEndianUint32(b, crc32, 8)
+	writeLittleEndianUint32(b, typeVal, 12)
+	copy(b[16:FooterSize], dvplFooter)
+}
+
+// readDVPLFooter reads the version 1 or 2 DVPL footer data from a DVPL buffer.
 func readDVPLFooter(buffer []byte) (*DVPLFooter, error) {
-	if len(buffer) < dvplFooterSize {
-		return nil, errors.New(colors.RedColor + "InvalidDVPLFooter: Buffer size is smaller than expected" + colors.ResetColor)
+	if len(buffer) < FooterSize {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFooter, ErrFileTooSmall)
 	}
 
-	footerBuffer := buffer[len(buffer)-dvplFooterSize:]
+	footerBuffer := buffer[len(buffer)-FooterSize:]
 
-	if string(footerBuffer[16:]) != dvplFooter {
-		return nil, errors.New(colors.RedColor + "InvalidDVPLFooter: Footer signature mismatch" + colors.ResetColor)
+	footerData := &DVPLFooter{Version: 1}
+	switch string(footerBuffer[16:]) {
+	case dvplFooter:
+	case dvplFooterV2:
+		if len(buffer) < FooterV2Size {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFooter, ErrFileTooSmall)
+		}
+		footerData.Version = 2
+		footerData.OriginalCRC32 = readLittleEndianUint32(buffer, len(buffer)-FooterV2Size)
+	default:
+		return nil, fmt.Errorf("%w: footer signature mismatch", ErrInvalidFooter)
 	}
 
-	footerData := &DVPLFooter{}
 	footerData.OriginalSize = readLittleEndianUint32(footerBuffer, 0)
 	footerData.CompressedSize = readLittleEndianUint32(footerBuffer, 4)
 	footerData.CRC32 = readLittleEndianUint32(footerBuffer, 8)
@@ -55,6 +142,98 @@ func readDVPLFooter(buffer []byte) (*DVPLFooter, error) {
 	return footerData, nil
 }
 
+// IsDVPL reports whether buffer already carries a well-formed DVPL footer: the trailing magic, a compressed
+// size matching the block in front of it and a known compression type. The CRC32 is not checked.
+func IsDVPL(buffer []byte) bool {
+	footerData, err := readDVPLFooter(buffer)
+	if err != nil {
+		return false
+	}
+	if uint32(len(buffer)-footerData.size()) != footerData.CompressedSize {
+		return false
+	}
+	return footerData.Type == TypeNone || footerData.Type == TypeLZ4
+}
+
+// TrimDVPLPadding returns buffer cut off after its DVPL footer, for files that tools padded to a block
+// boundary with bytes after the footer. The last MaxPadding bytes are scanned backward for the footer
+// magic and the first candidate whose compressed size matches the data in front of it is used.
+// The buffer is returned unchanged when no such footer is found.
+func TrimDVPLPadding(buffer []byte) []byte {
+	if IsDVPL(buffer) {
+		return buffer
+	}
+
+	lowest := len(buffer) - MaxPadding - FooterSize
+	if lowest < 0 {
+		lowest = 0
+	}
+	for end := len(buffer) - 1; end-FooterSize >= lowest; end-- {
+		magic := string(buffer[end-len(dvplFooter) : end])
+		if (magic == dvplFooter || magic == dvplFooterV2) && IsDVPL(buffer[:end]) {
+			return buffer[:end]
+		}
+	}
+	return buffer
+}
+
+// ReadFooterFile reads the DVPL footer of the file at path without loading the rest of the file.
+func ReadFooterFile(path string) (*DVPLFooter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() < FooterSize {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFooter, ErrFileTooSmall)
+	}
+
+	// Read enough for a version 2 footer, readDVPLFooter only looks at the tail
+	footerLength := int64(FooterV2Size)
+	if info.Size() < footerLength {
+		footerLength = info.Size()
+	}
+	footerBuffer := make([]byte, footerLength)
+	if _, err := file.ReadAt(footerBuffer, info.Size()-footerLength); err != nil {
+		return nil, err
+	}
+	return readDVPLFooter(footerBuffer)
+}
+
+// DecompressFile reads the DVPL file at path and returns its decompressed data.
+func DecompressFile(path string) ([]byte, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DecompressDVPL(buffer)
+}
+
+// CompressFile reads the file at path and returns its contents compressed into DVPL format.
+func CompressFile(path string) ([]byte, error) {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return CompressDVPL(buffer)
+}
+
+// TypeName returns a human-readable name for the footer's compression type.
+func (footer *DVPLFooter) TypeName() string {
+	switch footer.Type {
+	case TypeNone:
+		return "NONE"
+	case TypeLZ4:
+		return "LZ4"
+	}
+	return fmt.Sprintf("UNKNOWN (%d)", footer.Type)
+}
+
 // writeLittleEndianUint32 writes a little-endian uint32 value to a byte slice at the specified offset.
 func writeLittleEndianUint32(b []byte, v uint32, offset int) {
 	b[offset+0] = byte(v)
@@ -69,58 +248,371 @@ func readLittleEndianUint32(b []byte, offset int) uint32 {
 }
 
 // CompressDVPL compresses a buffer and returns the processed DVPL file buffer.
+// Empty buffers and data that LZ4 cannot shrink are stored uncompressed instead.
 func CompressDVPL(buffer []byte) ([]byte, error) {
-	// Calculate the maximum possible compressed block size
+	return CompressDVPLLevelInto(nil, buffer, 0)
+}
+
+// CompressDVPLWithFooter is like CompressDVPL but also returns the footer appended to the block, so
+// callers learn the sizes, CRC32 and compression type without parsing the end of the result.
+func CompressDVPLWithFooter(buffer []byte) ([]byte, DVPLFooter, error) {
+	return compressDVPLLevelInto(nil, buffer, 0)
+}
+
+// CompressDVPLInto is like CompressDVPL but writes the result into dst, reusing its capacity when it is
+// large enough, and returns the filled slice. dst must not overlap buffer.
+func CompressDVPLInto(dst, buffer []byte) ([]byte, error) {
+	return CompressDVPLLevelInto(dst, buffer, 0)
+}
+
+// CompressDVPLLevel compresses a buffer at the given level and returns the processed DVPL file buffer.
+// Level 0 uses the fast LZ4 compressor, levels 1 to MaxLevel use LZ4 high compression with a search
+// depth that doubles with every level. All levels produce a standard LZ4 block.
+func CompressDVPLLevel(buffer []byte, level int) ([]byte, error) {
+	return CompressDVPLLevelInto(nil, buffer, level)
+}
+
+// CompressDVPLLevelInto is like CompressDVPLLevel but writes the result into dst, reusing its capacity
+// when it is large enough, and returns the filled slice. dst must not overlap buffer.
+func CompressDVPLLevelInto(dst, buffer []byte, level int) ([]byte, error) {
+	result, _, err := compressDVPLLevelInto(dst, buffer, level)
+	return result, err
+}
+
+// CompressDVPLAcceleration compresses a buffer with the fast LZ4 compressor at the given acceleration,
+// like LZ4_compress_fast of the C library, and returns the processed DVPL file buffer. Acceleration 1
+// is what CompressDVPL does, higher values up to MaxAcceleration compress faster into larger blocks.
+func CompressDVPLAcceleration(buffer []byte, acceleration int) ([]byte, error) {
+	return CompressDVPLAccelerationInto(nil, buffer, acceleration)
+}
+
+// CompressDVPLAccelerationInto is like CompressDVPLAcceleration but writes the result into dst, reusing its
+// capacity when it is large enough, and returns the filled slice. dst must not overlap buffer.
+func CompressDVPLAccelerationInto(dst, buffer []byte, acceleration int) ([]byte, error) {
+	if acceleration < 1 || acceleration > MaxAcceleration {
+		return nil, fmt.Errorf("invalid acceleration %d, expected 1-%d", acceleration, MaxAcceleration)
+	}
+	result, _, err := compressDVPLInto(dst, buffer, 0, acceleration)
+	return result, err
+}
+
+// compressDVPLLevelInto implements CompressDVPLLevelInto and also returns the footer it appended.
+func compressDVPLLevelInto(dst, buffer []byte, level int) ([]byte, DVPLFooter, error) {
+	if level < 0 || level > MaxLevel {
+		return nil, DVPLFooter{}, fmt.Errorf("invalid compression level %d, expected 0-%d", level, MaxLevel)
+	}
+	return compressDVPLInto(dst, buffer, level, 1)
+}
+
+// compressDVPLInto compresses buffer into dst at level, or at acceleration with the fast compressor of
+// level 0, and returns the DVPL data together with the footer it appended.
+func compressDVPLInto(dst, buffer []byte, level, acceleration int) ([]byte, DVPLFooter, error) {
+
+	// Empty input has nothing to compress, store it as an empty block
+	if len(buffer) == 0 {
+		return compressDVPLStoredInto(dst, buffer)
+	}
+
+	// Calculate the maximum possible compressed block size, leaving room for the footer
 	compressedBlockSize := lz4.CompressBlockBound(len(buffer))
-	compressedBlock := make([]byte, compressedBlockSize)
+	if cap(dst) < compressedBlockSize+FooterSize {
+		dst = make([]byte, compressedBlockSize+FooterSize)
+	}
+	compressedBlock := dst[:compressedBlockSize]
 
 	// Compress the data
-	n, err := lz4.CompressBlock(buffer, compressedBlock, nil)
+	var n int
+	var err error
+	if level == 0 && acceleration > 1 {
+		n = compressBlockFast(buffer, compressedBlock, acceleration)
+	} else if level == 0 {
+		n, err = lz4.CompressBlock(buffer, compressedBlock, nil)
+	} else {
+		// Levels 1-9 match lz4.Level1-lz4.Level9, higher levels search deeper still
+		n, err = lz4.CompressBlockHC(buffer, compressedBlock, lz4.CompressionLevel(1<<(7+level)), nil, nil)
+	}
 	if err != nil {
-		return nil, err
+		return nil, DVPLFooter{}, err
 	}
 
-	// Trim the slice to actual compressed size
-	compressedBlock = compressedBlock[:n]
+	// Store the data as is when LZ4 does not make it any smaller
+	if n >= len(buffer) {
+		return compressDVPLStoredInto(dst, buffer)
+	}
 
-	// Create DVPL footer
-	footerBuffer := createDVPLFooter(uint32(len(buffer)), uint32(n), crc32.ChecksumIEEE(compressedBlock), dvplTypeLZ4)
+	// Append the DVPL footer right after the compressed data
+	result := dst[:n+FooterSize]
+	footer := DVPLFooter{
+		OriginalSize:   uint32(len(buffer)),
+		CompressedSize: uint32(n),
+		CRC32:          crc32.ChecksumIEEE(result[:n]),
+		Type:           TypeLZ4,
+		Version:        1,
+	}
+	putDVPLFooter(result[n:], footer.OriginalSize, footer.CompressedSize, footer.CRC32, footer.Type)
+	return result, footer, nil
+}
 
-	// Append footer to the compressed data
-	return append(compressedBlock, footerBuffer...), nil
+// CompressDVPLStored stores a buffer without compression and returns the processed DVPL file buffer.
+func CompressDVPLStored(buffer []byte) ([]byte, error) {
+	return CompressDVPLStoredInto(nil, buffer)
 }
 
-// DecompressDVPL decompresses a DVPL buffer and returns the uncompressed file buffer.
-func DecompressDVPL(buffer []byte) ([]byte, error) {
+// CompressDVPLStoredInto is like CompressDVPLStored but writes the result into dst, reusing its capacity
+// when it is large enough, and returns the filled slice. dst must not overlap buffer.
+func CompressDVPLStoredInto(dst, buffer []byte) ([]byte, error) {
+	result, _, err := compressDVPLStoredInto(dst, buffer)
+	return result, err
+}
+
+// compressDVPLStoredInto implements CompressDVPLStoredInto and also returns the footer it appended.
+func compressDVPLStoredInto(dst, buffer []byte) ([]byte, DVPLFooter, error) {
+	if cap(dst) < len(buffer)+FooterSize {
+		dst = make([]byte, len(buffer)+FooterSize)
+	}
+	result := dst[:len(buffer)+FooterSize]
+	copy(result, buffer)
+
+	// Append the DVPL footer, the original and stored sizes are identical
+	footer := DVPLFooter{
+		OriginalSize:   uint32(len(buffer)),
+		CompressedSize: uint32(len(buffer)),
+		CRC32:          crc32.ChecksumIEEE(buffer),
+		Type:           TypeNone,
+		Version:        1,
+	}
+	putDVPLFooter(result[len(buffer):], footer.OriginalSize, footer.CompressedSize, footer.CRC32, footer.Type)
+	return result, footer, nil
+}
+
+// readDVPLBlock validates the footer and block size of a DVPL buffer and returns the footer and compressed block.
+func readDVPLBlock(buffer []byte) (*DVPLFooter, []byte, error) {
 	// Read DVPL footer
 	footerData, err := readDVPLFooter(buffer)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Extract compressed block
-	targetBlock := buffer[:len(buffer)-dvplFooterSize]
+	targetBlock := buffer[:len(buffer)-footerData.size()]
 
 	// Check if compressed size matches the footer
 	if uint32(len(targetBlock)) != footerData.CompressedSize {
-		return nil, errors.New(colors.RedColor + "DVPLSizeMismatch" + colors.ResetColor)
+		return nil, nil, ErrSizeMismatch
+	}
+
+	return footerData, targetBlock, nil
+}
+
+// checkDVPLBlock validates the footer, block size and CRC32 of a DVPL buffer and returns the footer and compressed block.
+func checkDVPLBlock(buffer []byte) (*DVPLFooter, []byte, error) {
+	footerData, targetBlock, err := readDVPLBlock(buffer)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Check CRC32 checksum
 	if crc32.ChecksumIEEE(targetBlock) != footerData.CRC32 {
-		return nil, errors.New(colors.RedColor + "DVPLCRC32Mismatch" + colors.ResetColor)
+		return nil, nil, ErrCRC32Mismatch
+	}
+
+	return footerData, targetBlock, nil
+}
+
+// VerifyDVPLChecksum checks the footer, block size and CRC32 of a DVPL buffer without decompressing it.
+func VerifyDVPLChecksum(buffer []byte) error {
+	_, _, err := checkDVPLBlock(buffer)
+	return err
+}
+
+// checkOriginalCRC returns the decoded data of a block, or ErrCRC32Mismatch when a version 2 footer
+// records a different CRC32 for the original data.
+func checkOriginalCRC(footerData *DVPLFooter, decoded []byte) ([]byte, error) {
+	if footerData.Version == 2 && crc32.ChecksumIEEE(decoded) != footerData.OriginalCRC32 {
+		return nil, fmt.Errorf("%w: original data", ErrCRC32Mismatch)
+	}
+	return decoded, nil
+}
+
+// ValidateDVPL checks a DVPL buffer as thoroughly as DecompressDVPL without allocating the original data:
+// the footer, block size and CRC32 are checked, then the LZ4 sequences are walked to confirm they are
+// well formed and decode to exactly OriginalSize bytes, without writing any output. The original data
+// CRC32 of a version 2 footer is checked by streaming the decoded data, which needs only the 64 KiB
+// match window in memory.
+func ValidateDVPL(buffer []byte) error {
+	footerData, targetBlock, err := checkDVPLBlock(buffer)
+	if err != nil {
+		return err
+	}
+
+	switch footerData.Type {
+	case TypeNone:
+		if footerData.OriginalSize != footerData.CompressedSize {
+			return fmt.Errorf("%w: stored block size differs from original size", ErrSizeMismatch)
+		}
+		_, err := checkOriginalCRC(footerData, targetBlock)
+		return err
+	case TypeLZ4:
+		if err := validateLZ4Block(targetBlock, footerData.OriginalSize); err != nil || footerData.Version != 2 {
+			return err
+		}
+		decompressor, err := NewDecompressor(bytes.NewReader(buffer))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(io.Discard, decompressor)
+		return err
+	}
+	return fmt.Errorf("%w: type %d", ErrUnknownType, footerData.Type)
+}
+
+// validateLZ4Block walks the sequences of an LZ4 block, checking every literal run stays inside the
+// block and every match refers back into data already decoded, and that the block decodes to
+// originalSize bytes. It reads the block only and never materializes the output.
+func validateLZ4Block(block []byte, originalSize uint32) error {
+	decoded, err := lz4DecodedSize(block, uint64(originalSize))
+	if err != nil {
+		return err
+	}
+	if decoded != uint64(originalSize) {
+		return fmt.Errorf("%w: decoded size differs from original size", ErrSizeMismatch)
+	}
+	return nil
+}
+
+// lz4DecodedSize walks the sequences of an LZ4 block like validateLZ4Block and returns how many bytes
+// it decodes to, failing as soon as that exceeds limit.
+func lz4DecodedSize(block []byte, limit uint64) (uint64, error) {
+	var decoded uint64
+	for pos := 0; pos < len(block); {
+		token, literals, next, ok := parseLZ4Literals(block, pos)
+		if !ok || literals > len(block)-next {
+			return 0, errCorruptLZ4Block
+		}
+		pos = next + literals
+		decoded += uint64(literals)
+
+		// The last sequence holds literals only
+		if pos == len(block) {
+			break
+		}
+
+		offset, length, next, ok := parseLZ4Match(block, pos, token)
+		if !ok || offset == 0 || uint64(offset) > decoded {
+			return 0, errCorruptLZ4Block
+		}
+		pos = next
+		decoded += uint64(length)
+
+		if decoded > limit {
+			return 0, fmt.Errorf("%w: decoded size exceeds original size", ErrSizeMismatch)
+		}
+	}
+	return decoded, nil
+}
+
+// ToFooterV2 converts DVPL data with a version 1 footer, such as the output of CompressDVPL, to a
+// version 2 footer that also carries the CRC32 of original, the data that was compressed. It may
+// reuse the memory of dvplData.
+func ToFooterV2(dvplData, original []byte) ([]byte, error) {
+	footerData, err := readDVPLFooter(dvplData)
+	if err != nil {
+		return nil, err
+	}
+	if footerData.Version != 1 {
+		return nil, fmt.Errorf("%w: footer is already version %d", ErrInvalidFooter, footerData.Version)
+	}
+
+	// Insert the original CRC32 between the block and the footer, which moves 4 bytes further
+	footerStart := len(dvplData) - FooterSize
+	result := append(dvplData, make([]byte, FooterV2Size-FooterSize)...)
+	copy(result[footerStart+4:], result[footerStart:footerStart+FooterSize])
+	writeLittleEndianUint32(result, crc32.ChecksumIEEE(original), footerStart)
+	copy(result[len(result)-len(dvplFooterV2):], dvplFooterV2)
+	return result, nil
+}
+
+// DecompressDVPL decompresses a DVPL buffer and returns the uncompressed file buffer.
+// The footer CRC32 must match the compressed block, as it does for WoTB files.
+func DecompressDVPL(buffer []byte) ([]byte, error) {
+	footerData, targetBlock, err := checkDVPLBlock(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return decodeDVPLBlock(footerData, targetBlock)
+}
+
+// DecompressDVPLLenient is like DecompressDVPL but also accepts DVPL variants whose footer CRC32
+// covers the original data instead of the compressed block. The block CRC32 is tried first.
+func DecompressDVPLLenient(buffer []byte) ([]byte, error) {
+	footerData, targetBlock, err := readDVPLBlock(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(targetBlock) == footerData.CRC32 {
+		return decodeDVPLBlock(footerData, targetBlock)
+	}
+
+	// Retry the CRC32 against the decompressed data
+	deDVPLBlock, err := decodeDVPLBlock(footerData, targetBlock)
+	if err != nil {
+		return nil, ErrCRC32Mismatch
+	}
+	if crc32.ChecksumIEEE(deDVPLBlock) != footerData.CRC32 {
+		return nil, ErrCRC32Mismatch
+	}
+	return deDVPLBlock, nil
+}
+
+// DecompressDVPLInto is like DecompressDVPL but decompresses into dst, which the caller owns and must
+// hold at least the OriginalSize recorded in the footer, as read with ParseFooter. It returns the number
+// of bytes written to dst, mirroring lz4.UncompressBlock, and an error wrapping io.ErrShortBuffer when
+// dst is too small. dst must not overlap buffer.
+func DecompressDVPLInto(dst, buffer []byte) (int, error) {
+	footerData, targetBlock, err := checkDVPLBlock(buffer)
+	if err != nil {
+		return 0, err
 	}
+	if uint64(len(dst)) < uint64(footerData.OriginalSize) {
+		return 0, fmt.Errorf("%w: destination holds %d bytes, the original data is %d bytes", io.ErrShortBuffer, len(dst), footerData.OriginalSize)
+	}
+
+	decoded, err := decodeDVPLBlockInto(dst[:footerData.OriginalSize], footerData, targetBlock)
+	if err != nil {
+		return 0, err
+	}
+	return len(decoded), nil
+}
 
+// decodeDVPLBlock decompresses a validated block according to the footer type.
+func decodeDVPLBlock(footerData *DVPLFooter, targetBlock []byte) ([]byte, error) {
+	return decodeDVPLBlockInto(nil, footerData, targetBlock)
+}
+
+// decodeDVPLBlockInto is like decodeDVPLBlock but decodes into dst, which holds exactly OriginalSize
+// bytes. With a nil dst the LZ4 output is allocated and a stored block is returned as is.
+func decodeDVPLBlockInto(dst []byte, footerData *DVPLFooter, targetBlock []byte) ([]byte, error) {
 	// Decompress based on compression type
-	if footerData.Type == dvplTypeNone {
+	if footerData.Type == TypeNone {
 		// No compression applied, return the block as is
-		if footerData.OriginalSize != footerData.CompressedSize || footerData.Type != dvplTypeNone {
-			return nil, errors.New(colors.RedColor + "DVPLTypeSizeMismatch" + colors.ResetColor)
+		if footerData.OriginalSize != footerData.CompressedSize || footerData.Type != TypeNone {
+			return nil, fmt.Errorf("%w: stored block size differs from original size", ErrSizeMismatch)
+		}
+		if dst != nil {
+			targetBlock = dst[:copy(dst, targetBlock)]
+		}
+		return checkOriginalCRC(footerData, targetBlock)
+	} else if footerData.Type == TypeLZ4 {
+		// Refuse to allocate an original size no LZ4 block of this length could decode to
+		if uint64(footerData.OriginalSize) > uint64(footerData.CompressedSize)*MaxRatio {
+			return nil, fmt.Errorf("%w: original size %d is implausible for a %d byte LZ4 block", ErrSizeMismatch, footerData.OriginalSize, footerData.CompressedSize)
 		}
-		return targetBlock, nil
-	} else if footerData.Type == dvplTypeLZ4 {
+
 		// LZ4 compression, decompress the block
-		deDVPLBlock := make([]byte, footerData.OriginalSize)
+		deDVPLBlock := dst
+		if deDVPLBlock == nil {
+			deDVPLBlock = make([]byte, footerData.OriginalSize)
+		}
 		n, err := lz4.UncompressBlock(targetBlock, deDVPLBlock)
 		if err != nil {
 			return nil, err
@@ -128,12 +620,12 @@ func DecompressDVPL(buffer []byte) ([]byte, error) {
 
 		// Check if decompressed size matches the footer
 		if uint32(n) != footerData.OriginalSize {
-			return nil, errors.New(colors.RedColor + "DVPLDecodeSizeMismatch" + colors.ResetColor)
+			return nil, fmt.Errorf("%w: decoded size differs from original size", ErrSizeMismatch)
 		}
 
-		return deDVPLBlock, nil
+		return checkOriginalCRC(footerData, deDVPLBlock)
 	}
 
 	// Unknown compression type
-	return nil, errors.New(colors.RedColor + "UNKNOWN DVPL FORMAT" + colors.ResetColor)
+	return nil, fmt.Errorf("%w: type %d", ErrUnknownType, footerData.Type)
 }
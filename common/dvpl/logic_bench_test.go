@@ -0,0 +1,126 @@
+package dvpl
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// benchmarkPayloads returns repeated and random payloads of representative sizes, keyed by name.
+func benchmarkPayloads() ([]string, map[string][]byte) {
+	rng := rand.New(rand.NewSource(1))
+
+	var names []string
+	payloads := make(map[string][]byte)
+	for _, size := range []struct {
+		name  string
+		bytes int
+	}{{"1KB", 1 << 10}, {"64KB", 64 << 10}, {"4MB", 4 << 20}} {
+		random := make([]byte, size.bytes)
+		rng.Read(random)
+		repeated := bytes.Repeat([]byte("WoTB smart DLC asset data "), size.bytes/26+1)[:size.bytes]
+
+		for _, payload := range []struct {
+			kind string
+			data []byte
+		}{{"repeated", repeated}, {"random", random}} {
+			name := fmt.Sprintf("%s/%s", size.name, payload.kind)
+			names = append(names, name)
+			payloads[name] = payload.data
+		}
+	}
+	return names, payloads
+}
+
+func BenchmarkCompressDVPL(b *testing.B) {
+	names, payloads := benchmarkPayloads()
+	for _, name := range names {
+		payload := payloads[name]
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := CompressDVPL(payload); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCompressDVPLInto(b *testing.B) {
+	names, payloads := benchmarkPayloads()
+	for _, name := range names {
+		payload := payloads[name]
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			var dst []byte
+			for i := 0; i < b.N; i++ {
+				var err error
+				if dst, err = CompressDVPLInto(dst, payload); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCompressDVPLAcceleration(b *testing.B) {
+	names, payloads := benchmarkPayloads()
+	for _, acceleration := range []int{2, 8} {
+		for _, name := range names {
+			payload := payloads[name]
+			b.Run(fmt.Sprintf("%d/%s", acceleration, name), func(b *testing.B) {
+				b.SetBytes(int64(len(payload)))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := CompressDVPLAcceleration(payload, acceleration); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDecompressDVPL(b *testing.B) {
+	names, payloads := benchmarkPayloads()
+	for _, name := range names {
+		payload := payloads[name]
+		compressed, err := CompressDVPL(payload)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := DecompressDVPL(compressed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidateDVPL(b *testing.B) {
+	names, payloads := benchmarkPayloads()
+	for _, name := range names {
+		payload := payloads[name]
+		compressed, err := CompressDVPL(payload)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := ValidateDVPL(compressed); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,616 @@
+package dvpl
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBuffers returns a mix of compressible and incompressible buffers from a seeded PRNG.
+func testBuffers() [][]byte {
+	rng := rand.New(rand.NewSource(1))
+
+	var buffers [][]byte
+	for _, size := range []int{1, 15, 100, 4096, 65536, 300000} {
+		random := make([]byte, size)
+		rng.Read(random)
+
+		text := make([]byte, size)
+		for i := range text {
+			text[i] = "abcdefgh "[rng.Intn(9)]
+		}
+
+		buffers = append(buffers, random, text, bytes.Repeat([]byte{'x'}, size))
+	}
+	return buffers
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, buffer := range testBuffers() {
+		compressed, err := CompressDVPL(buffer)
+		if err != nil {
+			t.Fatalf("CompressDVPL(%d bytes): %v", len(buffer), err)
+		}
+
+		decompressed, err := DecompressDVPL(compressed)
+		if err != nil {
+			t.Fatalf("DecompressDVPL(%d bytes): %v", len(buffer), err)
+		}
+		if !bytes.Equal(decompressed, buffer) {
+			t.Fatalf("round trip of %d bytes returned different data", len(buffer))
+		}
+	}
+}
+
+func TestRoundTripLevels(t *testing.T) {
+	buffers := testBuffers()
+	for level := 0; level <= MaxLevel; level++ {
+		for _, buffer := range buffers {
+			compressed, err := CompressDVPLLevel(buffer, level)
+			if err != nil {
+				t.Fatalf("CompressDVPLLevel(%d bytes, %d): %v", len(buffer), level, err)
+			}
+
+			decompressed, err := DecompressDVPL(compressed)
+			if err != nil {
+				t.Fatalf("DecompressDVPL(%d bytes, level %d): %v", len(buffer), level, err)
+			}
+			if !bytes.Equal(decompressed, buffer) {
+				t.Fatalf("round trip of %d bytes at level %d returned different data", len(buffer), level)
+			}
+		}
+	}
+}
+
+func TestInvalidLevel(t *testing.T) {
+	for _, level := range []int{-1, MaxLevel + 1} {
+		if _, err := CompressDVPLLevel([]byte("data"), level); err == nil {
+			t.Errorf("CompressDVPLLevel accepted level %d", level)
+		}
+	}
+}
+
+func TestRoundTripAcceleration(t *testing.T) {
+	buffers := append(testBuffers(), bytes.Repeat([]byte("WoTB smart DLC asset data "), 10000))
+	for _, acceleration := range []int{1, 2, 4, 17, 100, MaxAcceleration} {
+		for _, buffer := range buffers {
+			compressed, err := CompressDVPLAcceleration(buffer, acceleration)
+			if err != nil {
+				t.Fatalf("CompressDVPLAcceleration(%d bytes, %d): %v", len(buffer), acceleration, err)
+			}
+			if err := ValidateDVPL(compressed); err != nil {
+				t.Fatalf("ValidateDVPL(%d bytes, acceleration %d): %v", len(buffer), acceleration, err)
+			}
+
+			decompressed, err := DecompressDVPL(compressed)
+			if err != nil {
+				t.Fatalf("DecompressDVPL(%d bytes, acceleration %d): %v", len(buffer), acceleration, err)
+			}
+			if !bytes.Equal(decompressed, buffer) {
+				t.Fatalf("round trip of %d bytes at acceleration %d returned different data", len(buffer), acceleration)
+			}
+		}
+	}
+
+	// Acceleration 1 is the default compressor, so existing outputs do not change
+	for _, buffer := range buffers {
+		accelerated, _ := CompressDVPLAcceleration(buffer, 1)
+		compressed, _ := CompressDVPL(buffer)
+		if !bytes.Equal(accelerated, compressed) {
+			t.Fatalf("acceleration 1 of %d bytes differs from CompressDVPL", len(buffer))
+		}
+	}
+
+	for _, acceleration := range []int{0, MaxAcceleration + 1} {
+		if _, err := CompressDVPLAcceleration([]byte("data"), acceleration); err == nil {
+			t.Errorf("CompressDVPLAcceleration accepted acceleration %d", acceleration)
+		}
+	}
+}
+
+func TestEmptyBuffer(t *testing.T) {
+	compressed, err := CompressDVPL(nil)
+	if err != nil {
+		t.Fatalf("CompressDVPL(nil): %v", err)
+	}
+	if len(compressed) != FooterSize {
+		t.Fatalf("compressed empty buffer is %d bytes, want %d", len(compressed), FooterSize)
+	}
+
+	decompressed, err := DecompressDVPL(compressed)
+	if err != nil {
+		t.Fatalf("DecompressDVPL: %v", err)
+	}
+	if len(decompressed) != 0 {
+		t.Fatalf("decompressed empty buffer is %d bytes", len(decompressed))
+	}
+}
+
+func TestEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.txt")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := CompressDVPL(data)
+	if err != nil {
+		t.Fatalf("CompressDVPL: %v", err)
+	}
+	footer, err := readDVPLFooter(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if footer.Type != TypeNone || footer.OriginalSize != 0 || footer.CompressedSize != 0 || footer.CRC32 != 0 {
+		t.Fatalf("unexpected footer for empty file: %+v", footer)
+	}
+
+	decompressed, err := DecompressDVPL(compressed)
+	if err != nil {
+		t.Fatalf("DecompressDVPL: %v", err)
+	}
+	if decompressed == nil || len(decompressed) != 0 {
+		t.Fatalf("DecompressDVPL returned %v, want an empty slice", decompressed)
+	}
+
+	// The streaming compressor produces the same output
+	var streamed bytes.Buffer
+	compressor, err := NewCompressor(&streamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compressor.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(streamed.Bytes(), compressed) {
+		t.Fatalf("NewCompressor wrote %x, want %x", streamed.Bytes(), compressed)
+	}
+
+	decompressor, err := NewDecompressor(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := io.ReadAll(decompressor); err != nil || len(data) != 0 {
+		t.Fatalf("NewDecompressor returned %d bytes, %v", len(data), err)
+	}
+}
+
+func TestCompressDecompressFile(t *testing.T) {
+	original := bytes.Repeat([]byte("file helper "), 200)
+	sourcePath := filepath.Join(t.TempDir(), "a.yaml")
+	if err := os.WriteFile(sourcePath, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, err := CompressFile(sourcePath)
+	if err != nil {
+		t.Fatalf("CompressFile: %v", err)
+	}
+	dvplPath := sourcePath + ".dvpl"
+	if err := os.WriteFile(dvplPath, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	decompressed, err := DecompressFile(dvplPath)
+	if err != nil {
+		t.Fatalf("DecompressFile: %v", err)
+	}
+	if !bytes.Equal(decompressed, original) {
+		t.Error("file does not round-trip")
+	}
+
+	if _, err := DecompressFile(filepath.Join(t.TempDir(), "missing.dvpl")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("DecompressFile(missing) = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestCorruptFooter(t *testing.T) {
+	compressed, err := CompressDVPL([]byte("some data to compress, some data to compress"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := append([]byte(nil), compressed...)
+	corrupt[len(corrupt)-1] = 'X'
+	if _, err := DecompressDVPL(corrupt); !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("bad signature: got %v, want %v", err, ErrInvalidFooter)
+	}
+
+	if _, err := DecompressDVPL(compressed[len(compressed)-FooterSize+1:]); !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("short buffer: got %v, want %v", err, ErrInvalidFooter)
+	}
+}
+
+func TestFileTooSmall(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "short.dvpl")
+	if err := os.WriteFile(path, []byte("DVPL!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadFooterFile(path); !errors.Is(err, ErrFileTooSmall) || !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("ReadFooterFile = %v, want %v and %v", err, ErrFileTooSmall, ErrInvalidFooter)
+	}
+	if _, err := DecompressFile(path); !errors.Is(err, ErrFileTooSmall) {
+		t.Errorf("DecompressFile = %v, want %v", err, ErrFileTooSmall)
+	}
+	if err := ValidateDVPL([]byte("DVPL!")); !errors.Is(err, ErrFileTooSmall) {
+		t.Errorf("ValidateDVPL = %v, want %v", err, ErrFileTooSmall)
+	}
+	if _, err := NewDecompressor(bytes.NewReader([]byte("DVPL!"))); !errors.Is(err, ErrFileTooSmall) {
+		t.Errorf("NewDecompressor = %v, want %v", err, ErrFileTooSmall)
+	}
+}
+
+func TestCRC32Mismatch(t *testing.T) {
+	compressed, err := CompressDVPL(bytes.Repeat([]byte("crc "), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed[0] ^= 0xff
+	if _, err := DecompressDVPL(compressed); !errors.Is(err, ErrCRC32Mismatch) {
+		t.Errorf("got %v, want %v", err, ErrCRC32Mismatch)
+	}
+	if err := VerifyDVPLChecksum(compressed); !errors.Is(err, ErrCRC32Mismatch) {
+		t.Errorf("VerifyDVPLChecksum: got %v, want %v", err, ErrCRC32Mismatch)
+	}
+}
+
+func TestSizeMismatch(t *testing.T) {
+	compressed, err := CompressDVPL(bytes.Repeat([]byte("size "), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the first byte of the block, keeping the footer intact
+	truncated := compressed[1:]
+	if _, err := DecompressDVPL(truncated); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("got %v, want %v", err, ErrSizeMismatch)
+	}
+}
+
+func TestImplausibleOriginalSize(t *testing.T) {
+	compressed, err := CompressDVPL(bytes.Repeat([]byte("ratio "), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Claim a 4 GB original without touching the block or its CRC32
+	writeLittleEndianUint32(compressed, 0xFFFFFFFF, len(compressed)-FooterSize)
+	if _, err := DecompressDVPL(compressed); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("got %v, want %v", err, ErrSizeMismatch)
+	}
+}
+
+func TestHighRatioRoundTrip(t *testing.T) {
+	zeros := make([]byte, 4<<20)
+	for _, level := range []int{0, MaxLevel} {
+		compressed, err := CompressDVPLLevel(zeros, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decompressed, err := DecompressDVPL(compressed)
+		if err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if !bytes.Equal(decompressed, zeros) {
+			t.Errorf("level %d: data does not round-trip", level)
+		}
+	}
+}
+
+func TestValidateDVPL(t *testing.T) {
+	for _, buffer := range testBuffers() {
+		for _, level := range []int{0, MaxLevel} {
+			compressed, err := CompressDVPLLevel(buffer, level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidateDVPL(compressed); err != nil {
+				t.Errorf("size %d level %d: ValidateDVPL = %v", len(buffer), level, err)
+			}
+		}
+	}
+}
+
+func TestValidateDVPLMatchesDecompress(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	compressed, err := CompressDVPL(bytes.Repeat([]byte("validate the sequences of this block "), 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockSize := len(compressed) - FooterSize
+
+	// Corrupt one block byte at a time and fix up the CRC32 so only the LZ4 data is wrong
+	for i := 0; i < 500; i++ {
+		corrupt := append([]byte(nil), compressed...)
+		corrupt[rng.Intn(blockSize)] = byte(rng.Intn(256))
+		writeLittleEndianUint32(corrupt, crc32.ChecksumIEEE(corrupt[:blockSize]), blockSize+8)
+
+		_, decompressErr := DecompressDVPL(corrupt)
+		validateErr := ValidateDVPL(corrupt)
+		if (decompressErr == nil) != (validateErr == nil) {
+			t.Fatalf("mutation %d: DecompressDVPL = %v, ValidateDVPL = %v", i, decompressErr, validateErr)
+		}
+	}
+}
+
+func TestParseFooterBytes(t *testing.T) {
+	buffer := []byte("footer round trip, footer round trip, footer round trip")
+	v1, err := CompressDVPL(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := ToFooterV2(append([]byte(nil), v1...), buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dvplData := range [][]byte{v1, v2} {
+		footer, err := ParseFooter(dvplData)
+		if err != nil {
+			t.Fatalf("ParseFooter: %v", err)
+		}
+		if footer.Type != TypeLZ4 || int(footer.CompressedSize)+len(footer.Bytes()) != len(dvplData) {
+			t.Errorf("version %d: footer %+v does not describe %d bytes", footer.Version, footer, len(dvplData))
+		}
+		if encoded := footer.Bytes(); !bytes.Equal(encoded, dvplData[footer.CompressedSize:]) {
+			t.Errorf("version %d: Bytes() = %x, want %x", footer.Version, encoded, dvplData[footer.CompressedSize:])
+		}
+	}
+
+	if len((&DVPLFooter{Version: 1}).Bytes()) != FooterSize || len((&DVPLFooter{Version: 2}).Bytes()) != FooterV2Size {
+		t.Error("Bytes() length does not match FooterSize and FooterV2Size")
+	}
+}
+
+func TestFooterV2(t *testing.T) {
+	for _, buffer := range testBuffers() {
+		compressed, err := CompressDVPL(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v2, err := ToFooterV2(compressed, buffer)
+		if err != nil {
+			t.Fatalf("ToFooterV2: %v", err)
+		}
+
+		footer, err := readDVPLFooter(v2)
+		if err != nil || footer.Version != 2 || footer.OriginalCRC32 != crc32.ChecksumIEEE(buffer) {
+			t.Fatalf("size %d: footer %+v, %v", len(buffer), footer, err)
+		}
+		if !IsDVPL(v2) {
+			t.Errorf("size %d: IsDVPL = false for a v2 footer", len(buffer))
+		}
+		if err := ValidateDVPL(v2); err != nil {
+			t.Errorf("size %d: ValidateDVPL = %v", len(buffer), err)
+		}
+
+		decompressed, err := DecompressDVPL(v2)
+		if err != nil || !bytes.Equal(decompressed, buffer) {
+			t.Errorf("size %d: DecompressDVPL does not round-trip: %v", len(buffer), err)
+		}
+
+		decompressor, err := NewDecompressor(bytes.NewReader(v2))
+		if err != nil {
+			t.Fatalf("NewDecompressor: %v", err)
+		}
+		streamed, err := io.ReadAll(decompressor)
+		if err != nil || !bytes.Equal(streamed, buffer) {
+			t.Errorf("size %d: NewDecompressor does not round-trip: %v", len(buffer), err)
+		}
+	}
+}
+
+func TestFooterV2OriginalCRC(t *testing.T) {
+	original := bytes.Repeat([]byte("original crc "), 100)
+	compressed, err := CompressDVPL(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the CRC32 of different data, so only the decoded bytes disagree with the footer
+	v2, err := ToFooterV2(compressed, []byte("something else"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecompressDVPL(v2); !errors.Is(err, ErrCRC32Mismatch) {
+		t.Errorf("DecompressDVPL = %v, want %v", err, ErrCRC32Mismatch)
+	}
+	if err := ValidateDVPL(v2); !errors.Is(err, ErrCRC32Mismatch) {
+		t.Errorf("ValidateDVPL = %v, want %v", err, ErrCRC32Mismatch)
+	}
+	decompressor, err := NewDecompressor(bytes.NewReader(v2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(decompressor); !errors.Is(err, ErrCRC32Mismatch) {
+		t.Errorf("NewDecompressor = %v, want %v", err, ErrCRC32Mismatch)
+	}
+}
+
+func TestTypeNone(t *testing.T) {
+	buffer := []byte("stored without compression")
+
+	stored, err := CompressDVPLStored(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	footer, err := readDVPLFooter(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if footer.Type != TypeNone {
+		t.Fatalf("footer type is %s, want NONE", footer.TypeName())
+	}
+	if !bytes.Equal(stored[:len(buffer)], buffer) {
+		t.Fatal("stored block differs from the input")
+	}
+
+	decompressed, err := DecompressDVPL(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, buffer) {
+		t.Fatal("round trip returned different data")
+	}
+
+	// Incompressible data falls back to the stored type
+	random := make([]byte, 1000)
+	rand.New(rand.NewSource(2)).Read(random)
+	compressed, err := CompressDVPL(random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if footer, _ := readDVPLFooter(compressed); footer.Type != TypeNone {
+		t.Fatalf("incompressible data stored as %s, want NONE", footer.TypeName())
+	}
+}
+
+func TestLenientCRC(t *testing.T) {
+	buffer := bytes.Repeat([]byte("original data crc "), 50)
+
+	compressed, err := CompressDVPL(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rewrite the footer CRC32 over the original data, as some variants do
+	variant := append([]byte(nil), compressed...)
+	writeLittleEndianUint32(variant, crc32.ChecksumIEEE(buffer), len(variant)-FooterSize+8)
+
+	if _, err := DecompressDVPL(variant); !errors.Is(err, ErrCRC32Mismatch) {
+		t.Errorf("strict: got %v, want %v", err, ErrCRC32Mismatch)
+	}
+
+	decompressed, err := DecompressDVPLLenient(variant)
+	if err != nil {
+		t.Fatalf("lenient: %v", err)
+	}
+	if !bytes.Equal(decompressed, buffer) {
+		t.Fatal("lenient round trip returned different data")
+	}
+
+	// Standard files still decompress, and real corruption is still caught
+	if _, err := DecompressDVPLLenient(compressed); err != nil {
+		t.Errorf("lenient on a standard file: %v", err)
+	}
+	variant[0] ^= 0xff
+	if _, err := DecompressDVPLLenient(variant); !errors.Is(err, ErrCRC32Mismatch) {
+		t.Errorf("lenient on corrupt data: got %v, want %v", err, ErrCRC32Mismatch)
+	}
+}
+
+func TestTrimDVPLPadding(t *testing.T) {
+	buffer := bytes.Repeat([]byte("padded "), 100)
+
+	compressed, err := CompressDVPL(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padded := append(append([]byte(nil), compressed...), make([]byte, 16)...)
+
+	if _, err := DecompressDVPL(padded); !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("strict: got %v, want %v", err, ErrInvalidFooter)
+	}
+
+	trimmed := TrimDVPLPadding(padded)
+	if !bytes.Equal(trimmed, compressed) {
+		t.Fatalf("trimmed to %d bytes, want %d", len(trimmed), len(compressed))
+	}
+	decompressed, err := DecompressDVPL(trimmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, buffer) {
+		t.Fatal("round trip returned different data")
+	}
+
+	// Unpadded and non-DVPL buffers are left alone
+	if trimmed := TrimDVPLPadding(compressed); len(trimmed) != len(compressed) {
+		t.Errorf("unpadded buffer trimmed to %d bytes", len(trimmed))
+	}
+	if trimmed := TrimDVPLPadding(buffer); len(trimmed) != len(buffer) {
+		t.Errorf("plain buffer trimmed to %d bytes", len(trimmed))
+	}
+}
+
+func TestCompressDVPLInto(t *testing.T) {
+	var dst []byte
+	for _, buffer := range testBuffers() {
+		want, err := CompressDVPL(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Reusing the previous output must give the same bytes as a fresh buffer
+		dst, err = CompressDVPLInto(dst, buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dst, want) {
+			t.Fatalf("CompressDVPLInto(%d bytes) differs from CompressDVPL", len(buffer))
+		}
+	}
+}
+
+func TestCompressDVPLWithFooter(t *testing.T) {
+	for _, buffer := range append(testBuffers(), []byte{}) {
+		compressed, footer, err := CompressDVPLWithFooter(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The returned footer must be the one written at the end of the result
+		parsed, err := ParseFooter(compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if footer != *parsed {
+			t.Fatalf("CompressDVPLWithFooter(%d bytes) footer = %+v, written footer = %+v", len(buffer), footer, *parsed)
+		}
+		if want, _ := CompressDVPL(buffer); !bytes.Equal(compressed, want) {
+			t.Fatalf("CompressDVPLWithFooter(%d bytes) differs from CompressDVPL", len(buffer))
+		}
+	}
+}
+
+func TestDecompressDVPLInto(t *testing.T) {
+	for _, buffer := range testBuffers() {
+		compressed, err := CompressDVPL(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		footer, err := ParseFooter(compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dst := make([]byte, footer.OriginalSize+8)
+		n, err := DecompressDVPLInto(dst, compressed)
+		if err != nil || !bytes.Equal(dst[:n], buffer) {
+			t.Errorf("%d byte %s block: %d bytes, %v, want the original data", len(buffer), footer.TypeName(), n, err)
+		}
+
+		if len(buffer) == 0 {
+			continue
+		}
+		if _, err := DecompressDVPLInto(dst[:footer.OriginalSize-1], compressed); !errors.Is(err, io.ErrShortBuffer) {
+			t.Errorf("%d byte block into a short buffer: %v, want io.ErrShortBuffer", len(buffer), err)
+		}
+	}
+
+	if _, err := DecompressDVPLInto(make([]byte, 16), []byte("not dvpl at all, no footer")); !errors.Is(err, ErrInvalidFooter) {
+		t.Errorf("invalid data: %v, want ErrInvalidFooter", err)
+	}
+}
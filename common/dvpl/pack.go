@@ -0,0 +1,155 @@
+package dvpl
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// A DVPL pack bundles many DVPL files into one. The entries are stored back to back, each one a
+// complete DVPL file (compressed block followed by its footer), followed by an index and a trailer:
+//
+//	entry 0 .. entry n-1   DVPL data
+//	index                  per entry: uint16 name length, name, uint64 offset, uint64 size
+//	trailer (16 bytes)     uint32 index size, uint32 entry count, uint32 index CRC32, "DVPK"
+//
+// All integers are little-endian. Names are slash-separated paths relative to the packed directory.
+const (
+	packTrailerSize = 16
+	packMagic       = "DVPK"
+)
+
+// ErrInvalidPack is returned when a pack trailer or index cannot be read.
+var ErrInvalidPack = errors.New("InvalidDVPLPack")
+
+// PackEntry describes one DVPL file inside a pack.
+type PackEntry struct {
+	Name   string // Slash-separated path relative to the packed directory
+	Offset uint64 // Offset of the DVPL data from the start of the pack
+	Size   uint64 // Size of the DVPL data, footer included
+}
+
+// PackWriter writes a DVPL pack. Close must be called to write the index.
+type PackWriter struct {
+	w       io.Writer
+	offset  uint64
+	entries []PackEntry
+}
+
+// NewPackWriter returns a PackWriter that writes a pack to w.
+func NewPackWriter(w io.Writer) *PackWriter {
+	return &PackWriter{w: w}
+}
+
+// Add appends an entry holding dvplData, which must be a complete DVPL file such as the output of CompressDVPL.
+func (p *PackWriter) Add(name string, dvplData []byte) error {
+	if len(name) > math.MaxUint16 {
+		return fmt.Errorf("%w: entry name too long: %s", ErrInvalidPack, name)
+	}
+	if !IsDVPL(dvplData) {
+		return fmt.Errorf("%w: entry %s is not DVPL data", ErrInvalidPack, name)
+	}
+
+	if _, err := p.w.Write(dvplData); err != nil {
+		return err
+	}
+	p.entries = append(p.entries, PackEntry{Name: name, Offset: p.offset, Size: uint64(len(dvplData))})
+	p.offset += uint64(len(dvplData))
+	return nil
+}
+
+// Close writes the index and trailer. It does not close the underlying writer.
+func (p *PackWriter) Close() error {
+	var index []byte
+	for _, entry := range p.entries {
+		index = binary.LittleEndian.AppendUint16(index, uint16(len(entry.Name)))
+		index = append(index, entry.Name...)
+		index = binary.LittleEndian.AppendUint64(index, entry.Offset)
+		index = binary.LittleEndian.AppendUint64(index, entry.Size)
+	}
+	if uint64(len(index)) > math.MaxUint32 {
+		return fmt.Errorf("%w: index exceeds the 4 GiB limit", ErrInvalidPack)
+	}
+
+	trailer := make([]byte, packTrailerSize)
+	writeLittleEndianUint32(trailer, uint32(len(index)), 0)
+	writeLittleEndianUint32(trailer, uint32(len(p.entries)), 4)
+	writeLittleEndianUint32(trailer, crc32.ChecksumIEEE(index), 8)
+	copy(trailer[12:], packMagic)
+
+	if _, err := p.w.Write(index); err != nil {
+		return err
+	}
+	_, err := p.w.Write(trailer)
+	return err
+}
+
+// ReadPackIndex reads the entry list of the pack in r, which is size bytes long.
+func ReadPackIndex(r io.ReaderAt, size int64) ([]PackEntry, error) {
+	if size < packTrailerSize {
+		return nil, fmt.Errorf("%w: file is smaller than the trailer", ErrInvalidPack)
+	}
+
+	trailer := make([]byte, packTrailerSize)
+	if _, err := r.ReadAt(trailer, size-packTrailerSize); err != nil {
+		return nil, err
+	}
+	if string(trailer[12:]) != packMagic {
+		return nil, fmt.Errorf("%w: trailer signature mismatch", ErrInvalidPack)
+	}
+
+	indexSize := int64(readLittleEndianUint32(trailer, 0))
+	entryCount := readLittleEndianUint32(trailer, 4)
+	if indexSize > size-packTrailerSize {
+		return nil, fmt.Errorf("%w: index is larger than the file", ErrInvalidPack)
+	}
+	dataSize := uint64(size - packTrailerSize - indexSize)
+
+	index := make([]byte, indexSize)
+	if _, err := r.ReadAt(index, int64(dataSize)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(index) != readLittleEndianUint32(trailer, 8) {
+		return nil, fmt.Errorf("%w: index CRC32 mismatch", ErrInvalidPack)
+	}
+
+	var entries []PackEntry
+	for pos := 0; pos < len(index); {
+		if len(index)-pos < 2 {
+			return nil, fmt.Errorf("%w: truncated index", ErrInvalidPack)
+		}
+		nameLength := int(binary.LittleEndian.Uint16(index[pos:]))
+		pos += 2
+		if len(index)-pos < nameLength+16 {
+			return nil, fmt.Errorf("%w: truncated index", ErrInvalidPack)
+		}
+
+		entry := PackEntry{Name: string(index[pos : pos+nameLength])}
+		pos += nameLength
+		entry.Offset = binary.LittleEndian.Uint64(index[pos:])
+		entry.Size = binary.LittleEndian.Uint64(index[pos+8:])
+		pos += 16
+
+		if entry.Offset > dataSize || entry.Size > dataSize-entry.Offset {
+			return nil, fmt.Errorf("%w: entry %s lies outside the pack data", ErrInvalidPack, entry.Name)
+		}
+		entries = append(entries, entry)
+	}
+
+	if uint32(len(entries)) != entryCount {
+		return nil, fmt.Errorf("%w: index lists %d entries, trailer says %d", ErrInvalidPack, len(entries), entryCount)
+	}
+	return entries, nil
+}
+
+// ReadPackEntry returns the DVPL data of an entry read from the pack in r.
+func ReadPackEntry(r io.ReaderAt, entry PackEntry) ([]byte, error) {
+	data := make([]byte, entry.Size)
+	if _, err := r.ReadAt(data, int64(entry.Offset)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,93 @@
+package dvpl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPackRoundTrip(t *testing.T) {
+	var pack bytes.Buffer
+	writer := NewPackWriter(&pack)
+
+	names := []string{"a.yaml", "maps/b.sc2", "maps/empty.txt"}
+	files := map[string][]byte{
+		"a.yaml":         bytes.Repeat([]byte("key: value\n"), 100),
+		"maps/b.sc2":     []byte("binary \x00\x01\x02 map data"),
+		"maps/empty.txt": {},
+	}
+	for _, name := range names {
+		compressed, err := CompressDVPL(files[name])
+		if err != nil {
+			t.Fatalf("CompressDVPL(%s): %v", name, err)
+		}
+		if err := writer.Add(name, compressed); err != nil {
+			t.Fatalf("Add(%s): %v", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reader := bytes.NewReader(pack.Bytes())
+	entries, err := ReadPackIndex(reader, int64(pack.Len()))
+	if err != nil {
+		t.Fatalf("ReadPackIndex: %v", err)
+	}
+	if len(entries) != len(names) {
+		t.Fatalf("got %d entries, want %d", len(entries), len(names))
+	}
+
+	for i, entry := range entries {
+		if entry.Name != names[i] {
+			t.Errorf("entry %d is named %q, want %q", i, entry.Name, names[i])
+		}
+		data, err := ReadPackEntry(reader, entry)
+		if err != nil {
+			t.Fatalf("ReadPackEntry(%s): %v", entry.Name, err)
+		}
+		decompressed, err := DecompressDVPL(data)
+		if err != nil {
+			t.Fatalf("DecompressDVPL(%s): %v", entry.Name, err)
+		}
+		if !bytes.Equal(decompressed, files[entry.Name]) {
+			t.Errorf("entry %s does not round-trip", entry.Name)
+		}
+	}
+}
+
+func TestPackRejectsNonDVPL(t *testing.T) {
+	writer := NewPackWriter(&bytes.Buffer{})
+	if err := writer.Add("plain.txt", []byte("not dvpl")); !errors.Is(err, ErrInvalidPack) {
+		t.Fatalf("Add(plain data) = %v, want ErrInvalidPack", err)
+	}
+}
+
+func TestCorruptPackIndex(t *testing.T) {
+	var pack bytes.Buffer
+	writer := NewPackWriter(&pack)
+	compressed, err := CompressDVPL([]byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Add("a.txt", compressed); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func([]byte) []byte{
+		"truncated": func(b []byte) []byte { return b[:packTrailerSize-1] },
+		"bad magic": func(b []byte) []byte { b[len(b)-1] ^= 0xFF; return b },
+		"bad index": func(b []byte) []byte { b[len(compressed)] ^= 0xFF; return b },
+	}
+	for name, corrupt := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := corrupt(append([]byte(nil), pack.Bytes()...))
+			if _, err := ReadPackIndex(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrInvalidPack) {
+				t.Errorf("ReadPackIndex = %v, want ErrInvalidPack", err)
+			}
+		})
+	}
+}
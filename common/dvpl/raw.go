@@ -0,0 +1,33 @@
+package dvpl
+
+import (
+	"fmt"
+	"hash/crc32"
+)
+
+// RawBlock returns the raw LZ4 block of a DVPL buffer without its footer, together with the size the
+// block decodes to, for LZ4 block decoders that know nothing about DVPL. The footer, block size and
+// CRC32 are checked first. Stored blocks hold no LZ4 data and are refused with ErrUnknownType.
+// The block shares the memory of buffer.
+func RawBlock(buffer []byte) (block []byte, originalSize uint32, err error) {
+	footerData, block, err := checkDVPLBlock(buffer)
+	if err != nil {
+		return nil, 0, err
+	}
+	if footerData.Type != TypeLZ4 {
+		return nil, 0, fmt.Errorf("%w: type %d block is not LZ4 compressed", ErrUnknownType, footerData.Type)
+	}
+	return block, footerData.OriginalSize, nil
+}
+
+// WrapRawBlock builds DVPL data from a raw LZ4 block and the size it decodes to, the reverse of RawBlock.
+// The block is walked to confirm it decodes to exactly originalSize bytes before the footer is appended.
+func WrapRawBlock(block []byte, originalSize uint32) ([]byte, error) {
+	if err := validateLZ4Block(block, originalSize); err != nil {
+		return nil, err
+	}
+	result := make([]byte, len(block)+FooterSize)
+	copy(result, block)
+	putDVPLFooter(result[len(block):], originalSize, uint32(len(block)), crc32.ChecksumIEEE(block), TypeLZ4)
+	return result, nil
+}
@@ -0,0 +1,59 @@
+package dvpl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+func TestRawBlockRoundTrip(t *testing.T) {
+	for _, buffer := range testBuffers() {
+		compressed, err := CompressDVPL(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		block, originalSize, err := RawBlock(compressed)
+		if errors.Is(err, ErrUnknownType) {
+			continue // Incompressible data is stored, not LZ4
+		}
+		if err != nil {
+			t.Fatalf("RawBlock(%d bytes): %v", len(buffer), err)
+		}
+
+		// The raw block decodes with a plain LZ4 block decoder
+		decoded := make([]byte, originalSize)
+		if n, err := lz4.UncompressBlock(block, decoded); err != nil || !bytes.Equal(decoded[:n], buffer) {
+			t.Fatalf("UncompressBlock of the raw block of %d bytes: %v", len(buffer), err)
+		}
+
+		wrapped, err := WrapRawBlock(block, originalSize)
+		if err != nil || !bytes.Equal(wrapped, compressed) {
+			t.Fatalf("WrapRawBlock(%d bytes) = %v, want the original DVPL data", len(buffer), err)
+		}
+	}
+}
+
+func TestRawBlockErrors(t *testing.T) {
+	stored, err := CompressDVPLStored([]byte("stored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RawBlock(stored); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("RawBlock of a stored block = %v, want ErrUnknownType", err)
+	}
+
+	compressed, err := CompressDVPL(bytes.Repeat([]byte("raw "), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, originalSize, err := RawBlock(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WrapRawBlock(block, originalSize+1); !errors.Is(err, ErrSizeMismatch) {
+		t.Errorf("WrapRawBlock with a wrong original size = %v, want ErrSizeMismatch", err)
+	}
+}
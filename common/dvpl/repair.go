@@ -0,0 +1,53 @@
+package dvpl
+
+import (
+	"fmt"
+	"hash/crc32"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// RepairDVPL rebuilds the footer of a DVPL buffer whose block is intact but whose footer records wrong
+// sizes or a wrong CRC32, as written by some third-party tools. The magic and footer version must be
+// readable. A block recorded as stored is kept stored; any other block is decoded as LZ4 without
+// trusting the recorded sizes, and a fresh footer is computed from the block and the decoded data.
+// repaired is false and buffer is returned as is when it already decompresses cleanly. Blocks that do
+// not decode return an error wrapping ErrUnrepairable.
+func RepairDVPL(buffer []byte) (result []byte, repaired bool, err error) {
+	if _, err := DecompressDVPL(buffer); err == nil {
+		return buffer, false, nil
+	}
+
+	footerData, err := readDVPLFooter(buffer)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrUnrepairable, err)
+	}
+	block := buffer[:len(buffer)-footerData.size()]
+
+	decoded := block
+	blockType := uint32(TypeNone)
+	if footerData.Type != TypeNone {
+		// The recorded original size cannot be trusted, so walk the block for the real one first
+		size, err := lz4DecodedSize(block, uint64(len(block))*MaxRatio)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %w", ErrUnrepairable, err)
+		}
+		decoded = make([]byte, size)
+		n, err := lz4.UncompressBlock(block, decoded)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %w", ErrUnrepairable, err)
+		}
+		if uint64(n) != size {
+			return nil, false, fmt.Errorf("%w: block decodes to %d bytes instead of %d", ErrUnrepairable, n, size)
+		}
+		blockType = TypeLZ4
+	}
+
+	result = append(block[:len(block):len(block)], createDVPLFooter(uint32(len(decoded)), uint32(len(block)), crc32.ChecksumIEEE(block), blockType)...)
+	if footerData.Version == 2 {
+		if result, err = ToFooterV2(result, decoded); err != nil {
+			return nil, false, err
+		}
+	}
+	return result, true, nil
+}
@@ -0,0 +1,61 @@
+package dvpl
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRepairDVPL(t *testing.T) {
+	for _, buffer := range testBuffers() {
+		compressed, err := CompressDVPL(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v2, err := ToFooterV2(append([]byte(nil), compressed...), buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, valid := range [][]byte{compressed, v2} {
+			// Corrupt the original size, compressed size and CRC32 fields of the footer
+			broken := append([]byte(nil), valid...)
+			footer := broken[len(broken)-FooterSize:]
+			for i := 0; i < 12; i++ {
+				footer[i] ^= 0x5a
+			}
+			if _, err := DecompressDVPL(broken); err == nil {
+				t.Fatalf("size %d: corrupted footer still decompresses", len(buffer))
+			}
+
+			repaired, changed, err := RepairDVPL(broken)
+			if err != nil || !changed {
+				t.Fatalf("size %d: RepairDVPL = %v, %v", len(buffer), changed, err)
+			}
+			if !bytes.Equal(repaired, valid) {
+				t.Errorf("size %d: repaired buffer differs from the original DVPL data", len(buffer))
+			}
+		}
+
+		if repaired, changed, err := RepairDVPL(compressed); err != nil || changed || !bytes.Equal(repaired, compressed) {
+			t.Errorf("size %d: RepairDVPL of a valid buffer = %v, %v", len(buffer), changed, err)
+		}
+	}
+}
+
+func TestRepairDVPLUnrepairable(t *testing.T) {
+	compressed, err := CompressDVPL(bytes.Repeat([]byte("repair me "), 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A match pointing before the start of the data cannot be decoded whatever the footer says
+	broken := append([]byte{0x0f, 0xff, 0xff}, compressed...)
+	if _, _, err := RepairDVPL(broken); !errors.Is(err, ErrUnrepairable) {
+		t.Errorf("RepairDVPL of a corrupt block = %v, want ErrUnrepairable", err)
+	}
+
+	if _, _, err := RepairDVPL([]byte("not dvpl at all")); !errors.Is(err, ErrUnrepairable) {
+		t.Errorf("RepairDVPL without a footer = %v, want ErrUnrepairable", err)
+	}
+}
@@ -0,0 +1,51 @@
+package dvpl
+
+// A raw LZ4 block is a run of sequences. Each starts with a token whose high nibble is the length of the
+// literal run that follows and whose low nibble is the match length beyond the 4-byte minimum, a nibble of
+// 15 being extended by continuation bytes. The literals are followed by the 2-byte little-endian offset of
+// the match and its length extension, except in the last sequence, which holds literals only.
+//
+// The validator, the streaming decoder and the streaming compressor all parse sequences with the two
+// functions below. They only read the bytes they are given, so the streaming decoder can call them on
+// the part of the block it has buffered and read more when they report that it ends too early.
+
+// parseLZ4Literals parses the token and literal run length of the sequence at pos of block. It returns the
+// token, the length of the literal run and the position of its first byte. ok is false when block ends
+// before the length does; the literals themselves are not required to be in block.
+func parseLZ4Literals(block []byte, pos int) (token byte, literals, next int, ok bool) {
+	if pos >= len(block) {
+		return 0, 0, pos, false
+	}
+	token = block[pos]
+	literals, next, ok = readLZ4Length(block, pos+1, int(token>>4))
+	return token, literals, next, ok
+}
+
+// parseLZ4Match parses the match offset and length at pos of block, which follow the literals of the
+// sequence that started with token. The length includes the 4-byte minimum. ok is false when block ends
+// before the length does.
+func parseLZ4Match(block []byte, pos int, token byte) (offset, length, next int, ok bool) {
+	if len(block)-pos < 2 {
+		return 0, 0, pos, false
+	}
+	offset = int(block[pos]) | int(block[pos+1])<<8
+	length, next, ok = readLZ4Length(block, pos+2, int(token&0xF))
+	return offset, length + lz4MinMatch, next, ok
+}
+
+// readLZ4Length extends a 4-bit LZ4 length with the continuation bytes at pos, returning the
+// length and the position after it. ok is false when the block ends inside the length.
+func readLZ4Length(block []byte, pos int, length int) (int, int, bool) {
+	if length != 0xF {
+		return length, pos, true
+	}
+	for pos < len(block) {
+		b := block[pos]
+		pos++
+		length += int(b)
+		if b != 0xFF {
+			return length, pos, true
+		}
+	}
+	return 0, pos, false
+}
@@ -0,0 +1,469 @@
+package dvpl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// Streaming codec limits. LZ4 matches never reach further back than 64 KiB,
+// so that is all the decoded history the decompressor has to keep around.
+const (
+	streamWindowSize = 64 << 10
+	streamChunkSize  = 64 << 10
+)
+
+var errCompressorClosed = errors.New("dvpl: write to closed compressor")
+
+// NewDecompressor returns a reader that streams the decompressed contents of the DVPL data in r.
+//
+// The footer is read first. If r implements io.ReadSeeker it is read from the end of the
+// stream, otherwise r is buffered in memory in full: the footer sits at the tail and records
+// whether the block is LZ4 or stored, so not a single byte can be decoded before the end of r
+// has been reached. Pass an *os.File or another io.ReadSeeker to stream large files.
+// The block is then decoded incrementally while holding at most the 64 KiB match window
+// plus one 64 KiB chunk of output, regardless of the original size. The CRC32 and the
+// original size are checked once the end of the block is reached, so corruption is
+// reported by the final Read rather than by NewDecompressor.
+//
+// DecompressDVPL and the other byte-slice functions do not go through this reader. They return
+// the whole original data anyway, and decoding it in place with lz4.UncompressBlock avoids the
+// window allocation and the copy of every byte through it, which makes small files, the bulk of
+// the game data, several times faster to decode.
+func NewDecompressor(r io.Reader) (io.Reader, error) {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		rs = bytes.NewReader(data)
+	}
+
+	// Read DVPL footer from the end of the stream
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size < FooterSize {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFooter, ErrFileTooSmall)
+	}
+
+	// Read enough for a version 2 footer, readDVPLFooter only looks at the tail
+	footerLength := int64(FooterV2Size)
+	if size < footerLength {
+		footerLength = size
+	}
+	if _, err := rs.Seek(size-footerLength, io.SeekStart); err != nil {
+		return nil, err
+	}
+	footerBuffer := make([]byte, footerLength)
+	if _, err := io.ReadFull(rs, footerBuffer); err != nil {
+		return nil, err
+	}
+	footerData, err := readDVPLFooter(footerBuffer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if compressed size matches the footer
+	if size-int64(footerData.size()) != int64(footerData.CompressedSize) {
+		return nil, ErrSizeMismatch
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	d := &decompressor{footer: footerData, crc: crc32.NewIEEE()}
+	if footerData.Version == 2 {
+		d.originalCRC = crc32.NewIEEE()
+	}
+	block := io.TeeReader(io.LimitReader(rs, int64(footerData.CompressedSize)), d.crc)
+
+	switch footerData.Type {
+	case TypeNone:
+		if footerData.OriginalSize != footerData.CompressedSize {
+			return nil, fmt.Errorf("%w: stored block size differs from original size", ErrSizeMismatch)
+		}
+		d.raw = block
+	case TypeLZ4:
+		d.src = block
+		d.in = make([]byte, 0, streamChunkSize)
+		d.hist = make([]byte, 0, streamWindowSize+streamChunkSize)
+	default:
+		return nil, fmt.Errorf("%w: type %d", ErrUnknownType, footerData.Type)
+	}
+
+	return d, nil
+}
+
+// Decoder phases within an LZ4 sequence.
+const (
+	phaseToken = iota
+	phaseLiterals
+	phaseMatch
+)
+
+// decompressor incrementally decodes a single DVPL block.
+type decompressor struct {
+	footer      *DVPLFooter
+	crc         hash.Hash32
+	originalCRC hash.Hash32 // CRC32 of the decoded bytes, for version 2 footers only
+	total       uint64      // Decoded bytes produced so far
+
+	raw io.Reader // Stored (uncompressed) block
+
+	src      io.Reader // LZ4 block
+	in       []byte    // Block bytes read from src, parsed from inPos on
+	inPos    int
+	hist     []byte // Decoded output, keeping the match window behind pos
+	pos      int    // Start of the decoded bytes not yet returned
+	phase    int
+	token    byte // Token of the current sequence
+	literals int  // Literal bytes left in the current sequence
+	match    int  // Match bytes left in the current sequence
+	offset   int  // Offset of the current match
+	err      error
+}
+
+func (d *decompressor) Read(p []byte) (int, error) {
+	if d.raw != nil {
+		n, err := d.raw.Read(p)
+		d.total += uint64(n)
+		d.hashOutput(p[:n])
+		if err == io.EOF {
+			err = d.finish()
+		}
+		return n, err
+	}
+
+	for d.pos == len(d.hist) && d.err == nil {
+		d.err = d.fill()
+	}
+
+	n := copy(p, d.hist[d.pos:])
+	d.pos += n
+	if n > 0 {
+		return n, nil
+	}
+	return 0, d.err
+}
+
+// fill decodes up to one chunk of output, dropping history that falls outside the match window.
+func (d *decompressor) fill() error {
+	if keep := len(d.hist) - streamWindowSize; keep > 0 {
+		d.hist = d.hist[:copy(d.hist, d.hist[keep:])]
+		d.pos -= keep
+	}
+
+	for len(d.hist)-d.pos < streamChunkSize {
+		switch d.phase {
+		case phaseToken:
+			token, literals, next, ok := parseLZ4Literals(d.in, d.inPos)
+			if !ok {
+				// Running out of block between sequences is its regular end
+				if err := d.readBlock(d.inPos == len(d.in)); err == io.EOF {
+					return d.finish()
+				} else if err != nil {
+					return err
+				}
+				continue
+			}
+			d.token, d.literals, d.inPos = token, literals, next
+			d.phase = phaseLiterals
+
+		case phaseLiterals:
+			if d.literals > 0 {
+				if d.inPos == len(d.in) {
+					if err := d.readBlock(false); err != nil {
+						return err
+					}
+				}
+				n := d.literals
+				if room := streamChunkSize - (len(d.hist) - d.pos); n > room {
+					n = room
+				}
+				if buffered := len(d.in) - d.inPos; n > buffered {
+					n = buffered
+				}
+				d.hist = append(d.hist, d.in[d.inPos:d.inPos+n]...)
+				d.hashOutput(d.hist[len(d.hist)-n:])
+				d.total += uint64(n)
+				d.inPos += n
+				d.literals -= n
+				continue
+			}
+
+			offset, length, next, ok := parseLZ4Match(d.in, d.inPos, d.token)
+			if !ok {
+				// The last sequence of a block carries literals only
+				if err := d.readBlock(d.inPos == len(d.in)); err == io.EOF {
+					return d.finish()
+				} else if err != nil {
+					return err
+				}
+				continue
+			}
+			if offset == 0 || offset > len(d.hist) {
+				return lz4.ErrInvalidSourceShortBuffer
+			}
+			d.offset, d.match, d.inPos = offset, length, next
+			d.phase = phaseMatch
+
+		case phaseMatch:
+			n := d.match
+			if room := streamChunkSize - (len(d.hist) - d.pos); n > room {
+				n = room
+			}
+			// Overlapping matches repeat the last offset bytes, so copy at most offset bytes at a time
+			for left := n; left > 0; {
+				step := left
+				if step > d.offset {
+					step = d.offset
+				}
+				start := len(d.hist) - d.offset
+				d.hist = append(d.hist, d.hist[start:start+step]...)
+				d.hashOutput(d.hist[len(d.hist)-step:])
+				left -= step
+			}
+			d.total += uint64(n)
+			d.match -= n
+			if d.match == 0 {
+				d.phase = phaseToken
+			}
+		}
+	}
+
+	return nil
+}
+
+// readBlock reads more of the block into in, keeping the bytes from inPos on. The buffer only grows
+// past one chunk for a sequence header longer than that, such as the length of a huge literal run.
+// At the end of the block it returns io.EOF when the block may end there, between sequences, and
+// lz4.ErrInvalidSourceShortBuffer when it is cut inside a sequence.
+func (d *decompressor) readBlock(mayEnd bool) error {
+	if d.inPos > 0 {
+		d.in = d.in[:copy(d.in, d.in[d.inPos:])]
+		d.inPos = 0
+	}
+	if len(d.in) == cap(d.in) {
+		d.in = append(d.in, make([]byte, cap(d.in))...)[:len(d.in)]
+	}
+
+	// Give up on readers that keep returning nothing, like bufio does
+	for i := 0; i < 100; i++ {
+		n, err := d.src.Read(d.in[len(d.in):cap(d.in)])
+		d.in = d.in[:len(d.in)+n]
+		if n > 0 {
+			return nil
+		}
+		if err == io.EOF && !mayEnd {
+			return lz4.ErrInvalidSourceShortBuffer
+		} else if err != nil {
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
+
+// hashOutput adds freshly decoded bytes to the original data CRC32 of a version 2 footer.
+func (d *decompressor) hashOutput(decoded []byte) {
+	if d.originalCRC != nil {
+		d.originalCRC.Write(decoded)
+	}
+}
+
+// finish validates the decoded stream against the footer once the block is exhausted.
+func (d *decompressor) finish() error {
+	if d.crc.Sum32() != d.footer.CRC32 {
+		return ErrCRC32Mismatch
+	}
+	if d.total != uint64(d.footer.OriginalSize) {
+		return fmt.Errorf("%w: decoded size differs from original size", ErrSizeMismatch)
+	}
+	if d.originalCRC != nil && d.originalCRC.Sum32() != d.footer.OriginalCRC32 {
+		return fmt.Errorf("%w: original data", ErrCRC32Mismatch)
+	}
+	return io.EOF
+}
+
+// NewCompressor returns a writer that compresses everything written to it into DVPL format on w.
+//
+// Input is compressed in 64 KiB chunks and the footer is written by Close, which must be called.
+// The chunks are stitched into the single LZ4 block the format requires by carrying each chunk's
+// trailing literal run over into the next one. Memory use is therefore about two chunks plus the
+// longest run of incompressible input, which stays small for typical game assets but grows with
+// data that LZ4 cannot compress at all. Matches do not span chunks, so the output may be slightly
+// larger than what CompressDVPL produces for the same input, which is why CompressDVPL keeps
+// compressing the whole buffer as one block instead of wrapping this writer.
+func NewCompressor(w io.Writer) (io.WriteCloser, error) {
+	return &compressor{
+		w:       w,
+		crc:     crc32.NewIEEE(),
+		pending: make([]byte, 0, streamChunkSize),
+	}, nil
+}
+
+// compressor builds a single DVPL block out of independently compressed chunks.
+type compressor struct {
+	w        io.Writer
+	crc      hash.Hash32
+	pending  []byte // Uncompressed input waiting for a full chunk
+	literals []byte // Trailing literal run carried into the next chunk
+	scratch  []byte
+	header   []byte
+	inSize   uint64
+	outSize  uint64
+	closed   bool
+}
+
+func (c *compressor) Write(p []byte) (int, error) {
+	if c.closed {
+		return 0, errCompressorClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := streamChunkSize - len(c.pending)
+		if n > len(p) {
+			n = len(p)
+		}
+		c.pending = append(c.pending, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(c.pending) == streamChunkSize {
+			if err := c.flushChunk(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close compresses any buffered input and writes the DVPL footer. It does not close the underlying writer.
+func (c *compressor) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	// Empty input is stored as an empty block, like CompressDVPL does
+	if c.inSize == 0 && len(c.pending) == 0 {
+		_, err := c.w.Write(createDVPLFooter(0, 0, crc32.ChecksumIEEE(nil), TypeNone))
+		return err
+	}
+
+	if err := c.flushChunk(true); err != nil {
+		return err
+	}
+	if c.inSize > math.MaxUint32 || c.outSize > math.MaxUint32 {
+		return fmt.Errorf("%w: stream exceeds the 4 GiB footer limit", ErrSizeMismatch)
+	}
+
+	footerBuffer := createDVPLFooter(uint32(c.inSize), uint32(c.outSize), c.crc.Sum32(), TypeLZ4)
+	_, err := c.w.Write(footerBuffer)
+	return err
+}
+
+// flushChunk compresses the pending input and writes every sequence except the trailing
+// literal run, which is held back unless this is the final chunk.
+func (c *compressor) flushChunk(final bool) error {
+	c.inSize += uint64(len(c.pending))
+
+	bound := lz4.CompressBlockBound(len(c.pending))
+	if cap(c.scratch) < bound {
+		c.scratch = make([]byte, bound)
+	}
+	n, err := lz4.CompressBlock(c.pending, c.scratch[:bound], nil)
+	if err != nil {
+		return err
+	}
+	block := c.scratch[:n]
+	c.pending = c.pending[:0]
+
+	// Locate the literals of the first sequence and the start of the last one
+	firstLitStart, firstLitEnd, lastStart, lastLitStart := 0, 0, 0, 0
+	for pos := 0; pos < len(block); {
+		token, literals, litStart, ok := parseLZ4Literals(block, pos)
+		if !ok {
+			return errCorruptLZ4Block
+		}
+		if pos == 0 {
+			firstLitStart, firstLitEnd = litStart, litStart+literals
+		}
+		lastStart, lastLitStart = pos, litStart
+		pos = litStart + literals
+		if pos >= len(block) {
+			break
+		}
+
+		if _, _, pos, ok = parseLZ4Match(block, pos, token); !ok {
+			return errCorruptLZ4Block
+		}
+	}
+
+	if lastStart == 0 {
+		// The chunk compressed to a single literal run
+		c.literals = append(c.literals, block[lastLitStart:]...)
+		if !final {
+			return nil
+		}
+		c.header = appendSequenceHeader(c.header[:0], len(c.literals), 0)
+		if err := c.emit(c.header, c.literals); err != nil {
+			return err
+		}
+		c.literals = c.literals[:0]
+		return nil
+	}
+
+	// Prefix the carried literals onto the first sequence and pass the rest through
+	firstLiterals := block[firstLitStart:firstLitEnd]
+	c.header = appendSequenceHeader(c.header[:0], len(c.literals)+len(firstLiterals), block[0]&0xF)
+	tail := block[firstLitEnd:]
+	if !final {
+		tail = block[firstLitEnd:lastStart]
+	}
+	if err := c.emit(c.header, c.literals, firstLiterals, tail); err != nil {
+		return err
+	}
+	c.literals = append(c.literals[:0], block[lastLitStart:]...)
+	if final {
+		c.literals = c.literals[:0]
+	}
+	return nil
+}
+
+// emit writes compressed block bytes to the destination, tracking their size and CRC32.
+func (c *compressor) emit(parts ...[]byte) error {
+	for _, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		if _, err := c.w.Write(part); err != nil {
+			return err
+		}
+		c.crc.Write(part)
+		c.outSize += uint64(len(part))
+	}
+	return nil
+}
+
+// appendSequenceHeader appends an LZ4 sequence token and literal length extension to b.
+func appendSequenceHeader(b []byte, literals int, matchNibble byte) []byte {
+	if literals < 0xF {
+		return append(b, byte(literals)<<4|matchNibble)
+	}
+	b = append(b, 0xF0|matchNibble)
+	for literals -= 0xF; literals >= 0xFF; literals -= 0xFF {
+		b = append(b, 0xFF)
+	}
+	return append(b, byte(literals))
+}
@@ -0,0 +1,79 @@
+package dvpl
+
+import (
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	for _, buffer := range testBuffers() {
+		var compressed bytes.Buffer
+		compressor, err := NewCompressor(&compressed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := compressor.Write(buffer); err != nil {
+			t.Fatalf("compressing %d bytes: %v", len(buffer), err)
+		}
+		if err := compressor.Close(); err != nil {
+			t.Fatalf("compressing %d bytes: %v", len(buffer), err)
+		}
+		if err := ValidateDVPL(compressed.Bytes()); err != nil {
+			t.Fatalf("ValidateDVPL(%d bytes): %v", len(buffer), err)
+		}
+
+		// A reader without Seek is buffered in full, one that can seek is read from its end
+		for _, r := range []io.Reader{iotest.OneByteReader(bytes.NewReader(compressed.Bytes())), bytes.NewReader(compressed.Bytes())} {
+			decompressor, err := NewDecompressor(r)
+			if err != nil {
+				t.Fatalf("NewDecompressor(%d bytes): %v", len(buffer), err)
+			}
+			decompressed, err := io.ReadAll(iotest.HalfReader(decompressor))
+			if err != nil || !bytes.Equal(decompressed, buffer) {
+				t.Fatalf("stream round trip of %d bytes: %v", len(buffer), err)
+			}
+		}
+	}
+}
+
+func TestStreamLongLiteralRun(t *testing.T) {
+	// A literal run whose length extension alone is longer than a chunk of the block
+	literals := bytes.Repeat([]byte("0123456789abcdef"), (17<<20)/16)
+	block := append(appendSequenceHeader(nil, len(literals), 0), literals...)
+	data := append(block, createDVPLFooter(uint32(len(literals)), uint32(len(block)), crc32.ChecksumIEEE(block), TypeLZ4)...)
+
+	decompressor, err := NewDecompressor(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decompressed, err := io.ReadAll(decompressor)
+	if err != nil || !bytes.Equal(decompressed, literals) {
+		t.Fatalf("long literal run: %d bytes, %v", len(decompressed), err)
+	}
+}
+
+func TestStreamTruncatedBlock(t *testing.T) {
+	buffer := testBuffers()[len(testBuffers())-2] // 300000 bytes of text
+	compressed, err := CompressDVPL(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cut the block inside a sequence and give it a footer that matches what is left
+	block := compressed[:len(compressed)/2]
+	data := append(block[:len(block):len(block)], createDVPLFooter(uint32(len(buffer)), uint32(len(block)), crc32.ChecksumIEEE(block), TypeLZ4)...)
+
+	decompressor, err := NewDecompressor(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(decompressor); !errors.Is(err, lz4.ErrInvalidSourceShortBuffer) {
+		t.Errorf("truncated block: %v, want %v", err, lz4.ErrInvalidSourceShortBuffer)
+	}
+}
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// BenchmarkStats summarizes an in-memory compression benchmark.
+type BenchmarkStats struct {
+	Files        int           // Number of files compressed
+	Failures     int           // Number of files that failed to compress
+	InputSize    int64         // Total size of the files read
+	OutputSize   int64         // Total size of the compressed data
+	Elapsed      time.Duration // Wall-clock time of the whole run
+	AverageRatio float64       // Mean of the per-file compressed/original size ratios
+	P50          time.Duration // Median per-file compression time
+	P90          time.Duration // 90th percentile per-file compression time
+	P99          time.Duration // 99th percentile per-file compression time
+}
+
+// InputRate returns the throughput of original data in bytes per second.
+func (stats BenchmarkStats) InputRate() float64 {
+	return float64(stats.InputSize) / stats.Elapsed.Seconds()
+}
+
+// OutputRate returns the throughput of compressed data in bytes per second.
+func (stats BenchmarkStats) OutputRate() float64 {
+	return float64(stats.OutputSize) / stats.Elapsed.Seconds()
+}
+
+// BenchmarkDVPLFiles compresses every eligible file below directoryOrFile in memory, using the threads,
+// level, store and filter settings of the config, and returns throughput and timing statistics.
+// Files are only read, nothing is written or deleted.
+func BenchmarkDVPLFiles(ctx context.Context, directoryOrFile string, config *Config) (BenchmarkStats, error) {
+	benchConfig := benchmarkConfig(config)
+
+	startTime := time.Now()
+	results, err := ProcessFilesContext(ctx, directoryOrFile, &benchConfig)
+	if err != nil {
+		return BenchmarkStats{Elapsed: time.Since(startTime)}, err
+	}
+	return summarizeBenchmark(results, time.Since(startTime)), nil
+}
+
+// benchmarkConfig returns a copy of config that compresses in memory only, overwriting nothing.
+func benchmarkConfig(config *Config) Config {
+	benchConfig := *config
+	benchConfig.Mode = "compress"
+	benchConfig.DryRun = true
+	benchConfig.SkipExisting = false
+	benchConfig.Overwrite = true
+	benchConfig.OnResult = nil
+	benchConfig.Progress = nil
+	return benchConfig
+}
+
+// summarizeBenchmark sums up the results of a benchmark run that took elapsed.
+func summarizeBenchmark(results []Result, elapsed time.Duration) BenchmarkStats {
+	stats := BenchmarkStats{Elapsed: elapsed}
+	var durations []time.Duration
+	var ratioSum float64
+	for _, result := range results {
+		if result.Failed() {
+			stats.Failures++
+			continue
+		}
+		if result.Ignored() {
+			continue
+		}
+
+		stats.Files++
+		stats.InputSize += result.InputSize
+		stats.OutputSize += result.OutputSize
+		if result.InputSize > 0 {
+			ratioSum += float64(result.OutputSize) / float64(result.InputSize)
+		}
+		durations = append(durations, result.Elapsed)
+	}
+
+	if stats.Files > 0 {
+		stats.AverageRatio = ratioSum / float64(stats.Files)
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	stats.P50 = percentile(durations, 50)
+	stats.P90 = percentile(durations, 90)
+	stats.P99 = percentile(durations, 99)
+
+	return stats
+}
+
+// autotuneSampleFiles is how many of the eligible files autotune converts at every thread count.
+const autotuneSampleFiles = 200
+
+// autotuneTolerance is how close to the best throughput a smaller thread count must come to be recommended.
+const autotuneTolerance = 0.95
+
+// ThreadTiming is the benchmark autotune measured at one thread count.
+type ThreadTiming struct {
+	Threads int
+	Stats   BenchmarkStats
+}
+
+// AutotuneThreads benchmarks compressing a sample of the eligible files below directoryOrFile at thread
+// counts from 1 to twice the number of CPUs and returns the timing of every count together with the
+// recommended -threads value: the smallest count within 5% of the best throughput, as more threads
+// only add memory use past that point. The sample is read once before timing, so the measurements
+// share a warm file cache. Like BenchmarkDVPLFiles, nothing is written or deleted.
+func AutotuneThreads(ctx context.Context, directoryOrFile string, config *Config) (timings []ThreadTiming, recommended int, err error) {
+	benchConfig := benchmarkConfig(config)
+	benchConfig.IOThreads = 0
+	benchConfig.CPUThreads = 0
+
+	listed, err := ListFilesContext(ctx, directoryOrFile, &benchConfig)
+	if err != nil {
+		return nil, 0, err
+	}
+	sample := sampleFiles(listed, autotuneSampleFiles)
+	if len(sample) == 0 {
+		return nil, 0, errors.New("no files to benchmark")
+	}
+
+	benchConfig.Threads = runtime.NumCPU()
+	if _, err := ProcessFileListContext(ctx, sample, &benchConfig); err != nil {
+		return nil, 0, err
+	}
+
+	for _, threads := range autotuneThreadCounts(runtime.NumCPU()) {
+		benchConfig.Threads = threads
+		startTime := time.Now()
+		results, err := ProcessFileListContext(ctx, sample, &benchConfig)
+		if err != nil {
+			return timings, 0, err
+		}
+		timings = append(timings, ThreadTiming{Threads: threads, Stats: summarizeBenchmark(results, time.Since(startTime))})
+	}
+	return timings, recommendThreads(timings), nil
+}
+
+// sampleFiles picks up to count of the files listed as eligible, spread evenly over the listing so
+// every part of the tree is represented.
+func sampleFiles(listed []Result, count int) []string {
+	var eligible []string
+	for _, result := range listed {
+		if !result.Failed() && !result.Ignored() {
+			eligible = append(eligible, result.Path)
+		}
+	}
+	sort.Strings(eligible)
+	if len(eligible) <= count {
+		return eligible
+	}
+
+	sample := make([]string, count)
+	for i := range sample {
+		sample[i] = eligible[i*len(eligible)/count]
+	}
+	return sample
+}
+
+// autotuneThreadCounts returns the thread counts autotune measures: the powers of two below twice cpus,
+// cpus itself and twice cpus, in increasing order.
+func autotuneThreadCounts(cpus int) []int {
+	var counts []int
+	for threads := 1; threads < 2*cpus; threads *= 2 {
+		if threads != cpus {
+			counts = append(counts, threads)
+		}
+	}
+	counts = append(counts, cpus, 2*cpus)
+	sort.Ints(counts)
+	return counts
+}
+
+// recommendThreads returns the smallest thread count of timings, which are in increasing order of
+// threads, whose throughput is within autotuneTolerance of the best one.
+func recommendThreads(timings []ThreadTiming) int {
+	var best float64
+	for _, timing := range timings {
+		if rate := timing.Stats.InputRate(); rate > best {
+			best = rate
+		}
+	}
+	for _, timing := range timings {
+		if timing.Stats.InputRate() >= best*autotuneTolerance {
+			return timing.Threads
+		}
+	}
+	return 1
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (len(sorted)*p + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAutotuneThreadCounts(t *testing.T) {
+	tests := []struct {
+		cpus int
+		want string
+	}{
+		{1, "[1 2]"},
+		{4, "[1 2 4 8]"},
+		{6, "[1 2 4 6 8 12]"},
+	}
+	for _, test := range tests {
+		if got := fmt.Sprint(autotuneThreadCounts(test.cpus)); got != test.want {
+			t.Errorf("autotuneThreadCounts(%d) = %s, want %s", test.cpus, got, test.want)
+		}
+	}
+}
+
+func TestRecommendThreads(t *testing.T) {
+	timing := func(threads int, elapsed time.Duration) ThreadTiming {
+		return ThreadTiming{Threads: threads, Stats: BenchmarkStats{InputSize: 1 << 20, Elapsed: elapsed}}
+	}
+	timings := []ThreadTiming{
+		timing(1, 400*time.Millisecond),
+		timing(2, 200*time.Millisecond),
+		timing(4, 104*time.Millisecond),
+		timing(8, 100*time.Millisecond),
+	}
+
+	// 4 threads come within 5% of the fastest 8, so the extra threads are not worth it
+	if got := recommendThreads(timings); got != 4 {
+		t.Errorf("recommendThreads = %d, want 4", got)
+	}
+}
+
+func TestAutotuneThreads(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.yaml", i)), []byte("autotune: true"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	timings, recommended, err := AutotuneThreads(context.Background(), dir, &Config{Mode: "autotune"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timings) == 0 || timings[0].Stats.Files != 5 || recommended < 1 {
+		t.Errorf("AutotuneThreads = %+v, %d, want every count to compress 5 files", timings, recommended)
+	}
+	for _, name := range []string{"0.yaml.dvpl", "4.yaml.dvpl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was written by autotune: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// csvHeader names the columns of the conversion log written by AppendCSV.
+var csvHeader = []string{"timestamp", "action", "source", "output", "original_size", "compressed_size", "crc32"}
+
+// AppendCSV appends one row per successful conversion of a run to the CSV conversion log at csvPath,
+// creating it with a header row first. Each row records when the file was converted, its source and
+// output paths, the original and compressed sizes and the CRC32 of the DVPL footer, so repeated runs
+// build up an audit trail in a single file.
+func AppendCSV(csvPath string, results []Result) error {
+	file, err := os.OpenFile(csvPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 {
+		writer.Write(csvHeader)
+	}
+	for _, result := range results {
+		if result.Failed() || result.Ignored() || result.OutputPath == "" {
+			continue
+		}
+		originalSize, compressedSize := result.InputSize, result.OutputSize
+		if result.Action == "decompress" {
+			originalSize, compressedSize = compressedSize, originalSize
+		}
+		writer.Write([]string{
+			result.Finished.Format(time.RFC3339),
+			result.Action,
+			result.Path,
+			result.OutputPath,
+			strconv.FormatInt(originalSize, 10),
+			strconv.FormatInt(compressedSize, 10),
+			fmt.Sprintf("%08x", result.CRC32),
+		})
+	}
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
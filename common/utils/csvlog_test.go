@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+func TestAppendCSV(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.yaml")
+	if err := os.WriteFile(path, []byte("csv: true\ncsv: true\ncsv: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	csvPath := filepath.Join(t.TempDir(), "log.csv")
+
+	results, err := ProcessFiles(path, &Config{Mode: "compress"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendCSV(csvPath, results); err != nil {
+		t.Fatal(err)
+	}
+	results, err = ProcessFiles(path+".dvpl", &Config{Mode: "decompress"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendCSV(csvPath, results); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(csvPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "timestamp" {
+		t.Fatalf("rows = %q, want a header and one row per run", rows)
+	}
+
+	compressed, err := dvpl.CompressDVPL([]byte("csv: true\ncsv: true\ncsv: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	footer, err := dvpl.ParseFooter(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crc := fmt.Sprintf("%08x", footer.CRC32)
+	size := fmt.Sprint(len(compressed))
+	want := [][]string{
+		{"compress", path, path + ".dvpl", "30", size, crc},
+		{"decompress", path + ".dvpl", path, "30", size, crc},
+	}
+	for i, row := range rows[1:] {
+		if fmt.Sprint(row[1:]) != fmt.Sprint(want[i]) {
+			t.Errorf("row %d = %q, want %q", i+1, row[1:], want[i])
+		}
+	}
+}
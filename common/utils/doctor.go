@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+const lz4Module = "github.com/pierrec/lz4/v4"
+
+// DoctorCheck is the outcome of one self-test of the doctor mode.
+type DoctorCheck struct {
+	Name string
+	Err  error // nil when the check passed
+}
+
+// DoctorEnvironment describes the build and machine the doctor mode ran on, for bug reports.
+type DoctorEnvironment struct {
+	GoVersion  string
+	OS         string
+	Arch       string
+	LZ4Version string // Version of the LZ4 library built in, "unknown" without build information
+}
+
+// RunDoctor checks that the DVPL codec works on this machine by converting an in-memory payload
+// every way the tool can, without touching the disk. Every check runs even if an earlier one fails.
+func RunDoctor() []DoctorCheck {
+	checks, _ := RunDoctorContext(context.Background())
+	return checks
+}
+
+// RunDoctorContext is like RunDoctor but stops once ctx is done, returning the checks run so far
+// together with an error wrapping the cause of the stop.
+func RunDoctorContext(ctx context.Context) ([]DoctorCheck, error) {
+	payload := doctorPayload()
+
+	steps := []struct {
+		name  string
+		check func() error
+	}{
+		{"LZ4 round trip", func() error { return checkRoundTrip(payload, dvpl.CompressDVPL) }},
+		{"LZ4 high compression round trip", func() error {
+			return checkRoundTrip(payload, func(data []byte) ([]byte, error) {
+				return dvpl.CompressDVPLLevel(data, dvpl.MaxLevel)
+			})
+		}},
+		{"LZ4 accelerated round trip", func() error {
+			return checkRoundTrip(payload, func(data []byte) ([]byte, error) {
+				return dvpl.CompressDVPLAcceleration(data, 8)
+			})
+		}},
+		{"stored round trip", func() error { return checkRoundTrip(payload, dvpl.CompressDVPLStored) }},
+		{"version 2 footer round trip", func() error {
+			return checkRoundTrip(payload, func(data []byte) ([]byte, error) {
+				compressed, err := dvpl.CompressDVPL(data)
+				if err != nil {
+					return nil, err
+				}
+				return dvpl.ToFooterV2(compressed, data)
+			})
+		}},
+		{"streaming round trip", func() error { return checkStreamRoundTrip(payload) }},
+		{"CRC32 detects corruption", func() error { return checkCorruptionDetected(payload) }},
+	}
+
+	var checks []DoctorCheck
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return checks, fmt.Errorf("processing interrupted: %w", err)
+		}
+		checks = append(checks, DoctorCheck{step.name, step.check()})
+	}
+	return checks, nil
+}
+
+// Environment returns the Go version, platform and LZ4 library version of the running binary.
+func Environment() DoctorEnvironment {
+	environment := DoctorEnvironment{
+		GoVersion:  runtime.Version(),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+		LZ4Version: "unknown",
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == lz4Module {
+				environment.LZ4Version = dep.Version
+			}
+		}
+	}
+	return environment
+}
+
+// doctorPayload mixes repetitive text, which LZ4 shrinks, with random bytes, which it cannot.
+func doctorPayload() []byte {
+	payload := bytes.Repeat([]byte("dvpl_lz4 doctor payload: compress, decompress, verify.\n"), 2000)
+	noise := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(noise)
+	return append(payload, noise...)
+}
+
+// checkRoundTrip compresses payload with compress and checks that the result validates and decompresses back.
+func checkRoundTrip(payload []byte, compress func([]byte) ([]byte, error)) error {
+	compressed, err := compress(payload)
+	if err != nil {
+		return fmt.Errorf("compressing: %w", err)
+	}
+	if err := dvpl.ValidateDVPL(compressed); err != nil {
+		return fmt.Errorf("validating: %w", err)
+	}
+	decompressed, err := dvpl.DecompressDVPL(compressed)
+	if err != nil {
+		return fmt.Errorf("decompressing: %w", err)
+	}
+	if !bytes.Equal(decompressed, payload) {
+		return errors.New("decompressed data differs from the original")
+	}
+	return nil
+}
+
+// checkStreamRoundTrip runs payload through the streaming compressor and decompressor.
+func checkStreamRoundTrip(payload []byte) error {
+	var compressed bytes.Buffer
+	compressor, err := dvpl.NewCompressor(&compressed)
+	if err != nil {
+		return err
+	}
+	if _, err := compressor.Write(payload); err != nil {
+		return fmt.Errorf("compressing: %w", err)
+	}
+	if err := compressor.Close(); err != nil {
+		return fmt.Errorf("compressing: %w", err)
+	}
+
+	decompressor, err := dvpl.NewDecompressor(bytes.NewReader(compressed.Bytes()))
+	if err != nil {
+		return fmt.Errorf("decompressing: %w", err)
+	}
+	decompressed, err := io.ReadAll(decompressor)
+	if err != nil {
+		return fmt.Errorf("decompressing: %w", err)
+	}
+	if !bytes.Equal(decompressed, payload) {
+		return errors.New("decompressed data differs from the original")
+	}
+	return nil
+}
+
+// checkCorruptionDetected flips a bit in a compressed block and checks that the CRC32 catches it.
+func checkCorruptionDetected(payload []byte) error {
+	compressed, err := dvpl.CompressDVPL(payload)
+	if err != nil {
+		return fmt.Errorf("compressing: %w", err)
+	}
+	if err := dvpl.VerifyDVPLChecksum(compressed); err != nil {
+		return fmt.Errorf("checking an intact file: %w", err)
+	}
+
+	compressed[len(compressed)/2] ^= 0x01
+	if err := dvpl.VerifyDVPLChecksum(compressed); !errors.Is(err, dvpl.ErrCRC32Mismatch) {
+		return fmt.Errorf("corrupted block gave %v, want %v", err, dvpl.ErrCRC32Mismatch)
+	}
+	return nil
+}
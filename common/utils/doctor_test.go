@@ -0,0 +1,11 @@
+package utils
+
+import "testing"
+
+func TestRunDoctor(t *testing.T) {
+	for _, check := range RunDoctor() {
+		if check.Err != nil {
+			t.Errorf("%s: %v", check.Name, check.Err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// exportFormat is a standard compression format the data of .dvpl files can be exported to.
+type exportFormat struct {
+	extension string
+	encode    func(data []byte) ([]byte, error)
+}
+
+// exportFormats are the formats accepted by -format, keyed by name.
+var exportFormats = map[string]exportFormat{
+	"lz4":  {".lz4", encodeLZ4Frame},
+	"gzip": {".gz", encodeGzip},
+	"zstd": {".zst", encodeZstd},
+}
+
+// ExportLZ4FilesContext decompresses the .dvpl files in directoryOrFile and re-encodes their data as
+// standard LZ4 frame files next to them, or below config.Output, so they can be opened with common
+// lz4 tooling. It is ExportFilesContext with the lz4 format, whatever config.Format says.
+func ExportLZ4FilesContext(ctx context.Context, directoryOrFile string, config *Config) ([]Result, error) {
+	lz4Config := *config
+	lz4Config.Format = "lz4"
+	return ExportFilesContext(ctx, directoryOrFile, &lz4Config)
+}
+
+// ExportFilesContext decompresses the .dvpl files in directoryOrFile and re-encodes their data in
+// config.Format, "lz4", "gzip" or "zstd", next to them or below config.Output, for tools and archives that do
+// not know DVPL. The .dvpl files are never modified or deleted. It walks and filters files like
+// ProcessFilesContext and returns one Result per file.
+func ExportFilesContext(ctx context.Context, directoryOrFile string, config *Config) ([]Result, error) {
+	if _, ok := exportFormats[config.Format]; !ok {
+		return nil, fmt.Errorf("unknown export format %q, expected lz4, gzip or zstd", config.Format)
+	}
+	return walkFiles(ctx, directoryOrFile, config, (*processRun).exportFile)
+}
+
+// exportFile writes the exported sidecar of a single .dvpl file.
+func (run *processRun) exportFile(path string, info os.FileInfo) Result {
+	config := run.config
+	result := Result{Path: path, Action: config.Mode}
+	format := exportFormats[config.Format]
+
+	// Export picks the same files decompress mode would
+	modeConfig := *config
+	modeConfig.Mode = "decompress"
+	if process, reason := shouldProcess(path, &modeConfig, run.executablePath); !process {
+		result.Action = "ignore"
+		result.Reason = reason
+		return result
+	}
+	if !modifiedSince(info, config) {
+		result.Action = "ignore"
+		result.Reason = "file not modified since -since"
+		return result
+	}
+
+	newName, err := run.outputPath(config.trimCompressedExt(path) + format.extension)
+	if err != nil {
+		result.Err = fmt.Errorf("preparing output: %w", err)
+		return result
+	}
+	result.OutputPath = newName
+
+	if existingOutput(&result, config) {
+		return result
+	}
+
+	readStart := time.Now()
+	fileData, err := os.ReadFile(path)
+	result.ReadTime = time.Since(readStart)
+	if err != nil {
+		result.Err = fmt.Errorf("reading file: %w", err)
+		return result
+	}
+	result.InputSize = int64(len(fileData))
+
+	convertStart := time.Now()
+	var exported []byte
+	data, err := decompressDVPL(fileData, config)
+	if err == nil {
+		exported, err = format.encode(data)
+	}
+	result.Elapsed = time.Since(convertStart)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.OutputSize = int64(len(exported))
+
+	if config.DryRun {
+		return result
+	}
+
+	if config.Output != "" {
+		if err := os.MkdirAll(filepath.Dir(newName), 0755); err != nil {
+			result.Err = fmt.Errorf("preparing output: %w", err)
+			return result
+		}
+	}
+
+	var modTime time.Time
+	if config.PreserveTimes {
+		modTime = info.ModTime()
+	}
+
+	writeStart := time.Now()
+	err = writeFileAtomic(newName, exported, modTime)
+	result.WriteTime = time.Since(writeStart)
+	if err != nil {
+		result.Err = fmt.Errorf("writing file %s: %w", newName, err)
+	}
+	return result
+}
+
+// encodeLZ4Frame encodes data as an LZ4 frame recording the content size.
+func encodeLZ4Frame(data []byte) ([]byte, error) {
+	var frame bytes.Buffer
+	writer := lz4.NewWriter(&frame)
+	if err := writer.Apply(lz4.SizeOption(uint64(len(data))), lz4.ChecksumOption(true)); err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return frame.Bytes(), nil
+}
+
+// encodeGzip encodes data as a gzip stream at the default compression level.
+func encodeGzip(data []byte) ([]byte, error) {
+	var stream bytes.Buffer
+	writer := gzip.NewWriter(&stream)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return stream.Bytes(), nil
+}
+
+// encodeZstd encodes data as a single zstd frame at the default compression level.
+func encodeZstd(data []byte) ([]byte, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer encoder.Close()
+	return encoder.EncodeAll(data, nil), nil
+}
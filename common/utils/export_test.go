@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+func TestEncodeLZ4Frame(t *testing.T) {
+	original := bytes.Repeat([]byte("frame export "), 500)
+	frame, err := encodeLZ4Frame(original)
+	if err != nil {
+		t.Fatalf("encodeLZ4Frame: %v", err)
+	}
+
+	decoded, err := io.ReadAll(lz4.NewReader(bytes.NewReader(frame)))
+	if err != nil {
+		t.Fatalf("reading frame: %v", err)
+	}
+	if !bytes.Equal(decoded, original) {
+		t.Error("frame does not decode to the original data")
+	}
+}
+
+func TestExportGzip(t *testing.T) {
+	original := bytes.Repeat([]byte("gzip export "), 500)
+	compressed, err := dvpl.CompressDVPL(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.yaml.dvpl"), compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ExportFilesContext(context.Background(), dir, &Config{Mode: "export", Format: "gzip", Overwrite: true})
+	if success, failure, _ := CountResults(results); err != nil || success != 1 || failure != 0 {
+		t.Fatalf("%d exported and %d failed, err %v, want 1, 0, nil", success, failure, err)
+	}
+
+	stream, err := os.Open(filepath.Join(dir, "data.yaml.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	reader, err := gzip.NewReader(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(decoded, original) {
+		t.Errorf("gzip sidecar does not decode to the original data: %v", err)
+	}
+
+	if _, err := ExportFilesContext(context.Background(), dir, &Config{Mode: "export", Format: "brotli"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestExportZstd(t *testing.T) {
+	original := bytes.Repeat([]byte("zstd export "), 500)
+	compressed, err := dvpl.CompressDVPL(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.yaml.dvpl"), compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ExportFilesContext(context.Background(), dir, &Config{Mode: "export", Format: "zstd", Overwrite: true})
+	if success, failure, _ := CountResults(results); err != nil || success != 1 || failure != 0 {
+		t.Fatalf("%d exported and %d failed, err %v, want 1, 0, nil", success, failure, err)
+	}
+
+	stream, err := os.ReadFile(filepath.Join(dir, "data.yaml.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer decoder.Close()
+	decoded, err := decoder.DecodeAll(stream, nil)
+	if err != nil || !bytes.Equal(decoded, original) {
+		t.Errorf("zstd sidecar does not decode to the original data: %v", err)
+	}
+}
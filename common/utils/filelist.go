@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadFileList reads the paths listed in a -from-file list, one per line. Blank lines and lines
+// starting with # are skipped, and surrounding spaces are trimmed.
+func ReadFileList(listPath string) ([]string, error) {
+	file, err := os.Open(listPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading file list %s: %w", listPath, err)
+	}
+	return paths, nil
+}
+
+// ProcessFileListContext converts exactly the given files, as read by ReadFileList, instead of walking a
+// directory. Listed directories are walked as usual. The files are filtered like in ProcessFilesContext
+// and converted concurrently by up to config.Threads workers. A listed path that does not exist is
+// reported as a failed Result. Relative paths are resolved against the working directory, which is
+// also the tree -output mirrors and the one -prune-empty removes emptied directories from.
+func ProcessFileListContext(ctx context.Context, paths []string, config *Config) ([]Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("processing interrupted: %w", err)
+	}
+
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	run, err := newProcessRun(ctx, config, root, (*processRun).processFile)
+	if err != nil {
+		return nil, err
+	}
+	defer run.cancel(nil)
+
+	pool := run.newPool(true)
+	for _, path := range paths {
+		if run.ctx.Err() != nil {
+			break
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			err = run.processDirectory(path, 1, pool)
+		} else if err == nil {
+			run.queue(pool, path, info)
+		}
+		if err != nil && run.ctx.Err() == nil {
+			run.report(Result{Path: path, Action: config.Mode, Err: err})
+		}
+	}
+	run.queueHeld(pool)
+	pool.wait()
+	run.stages.wait()
+
+	if config.PruneEmpty && !config.DryRun {
+		if _, err := pruneEmptyDirs(root, run.results); err != nil && run.ctx.Err() == nil {
+			return run.results, err
+		}
+	}
+
+	if run.ctx.Err() != nil {
+		return run.results, fmt.Errorf("processing interrupted: %w", context.Cause(run.ctx))
+	}
+	return run.results, nil
+}
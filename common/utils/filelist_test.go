@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProcessFileList(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "unlisted.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("file list test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listPath := filepath.Join(dir, "list.txt")
+	list := "# files to convert\n" + filepath.Join(dir, "a.txt") + "\n\n  " + filepath.Join(dir, "b.txt") + "  \n" + filepath.Join(dir, "missing.txt") + "\n"
+	if err := os.WriteFile(listPath, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := ReadFileList(listPath)
+	if err != nil {
+		t.Fatalf("ReadFileList: %v", err)
+	}
+	want := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt"), filepath.Join(dir, "missing.txt")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("ReadFileList = %q, want %q", paths, want)
+	}
+
+	results, err := ProcessFileListContext(context.Background(), paths, &Config{Mode: "compress", Overwrite: true, KeepOriginals: true})
+	if err != nil {
+		t.Fatalf("ProcessFileListContext: %v", err)
+	}
+	if success, failure, ignored := CountResults(results); success != 2 || failure != 1 || ignored != 0 {
+		t.Errorf("%d succeeded, %d failed and %d ignored, want 2, 1 and 0", success, failure, ignored)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unlisted.txt.dvpl")); err == nil {
+		t.Error("unlisted file was converted")
+	}
+}
@@ -1,40 +1,169 @@
 package utils
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/rifsxd/dvpl_lz4/common/colors"
 	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+	"gopkg.in/yaml.v3"
 )
 
 var GlobalPath string
 
+// Output is where the command-line log is written. It defaults to os.Stdout.
+var Output io.Writer = os.Stdout
+
 const (
 	dvplExtension = ".dvpl"
 )
 
 // Config represents the configuration for the program.
+// The yaml tags match the command-line flag names and are used to load -config files.
 type Config struct {
-	Mode          string
-	KeepOriginals bool
-	Path          string // New field to specify the directory path.
-	Ignore        string
-	IgnoreExt     bool
-	Verbose       bool // New field to specify verbose mode.
+	Mode           string        `yaml:"mode"`
+	KeepOriginals  bool          `yaml:"keep-originals"`
+	PruneEmpty     bool          `yaml:"prune-empty"` // Remove the directories below the input that deleting originals left empty.
+	Path           string        `yaml:"path"`        // New field to specify the directory path.
+	Paths          []string      `yaml:"-"`           // Every path to process: Path followed by any trailing command-line arguments.
+	FromFile       string        `yaml:"from-file"`   // File listing the paths to convert, one per line, instead of walking Path.
+	Ignore         string        `yaml:"ignore"`
+	Include        string        `yaml:"include"`     // Comma-separated glob patterns, only matching files are processed.
+	ExcludeDir     string        `yaml:"exclude-dir"` // Comma-separated directory names or glob patterns whose whole subtree is skipped.
+	SkipHidden     bool          `yaml:"skip-hidden"` // Skip files and directories whose names start with a dot.
+	IgnoreExt      bool          `yaml:"-"`
+	Verbose        bool          `yaml:"verbose"`          // New field to specify verbose mode.
+	Quiet          bool          `yaml:"quiet"`            // Print only the final summary line, without banner, per-file lines or timing.
+	QuietOnSuccess bool          `yaml:"quiet-on-success"` // Print nothing when verify finds no failures, only the failures and summary otherwise.
+	LogFile        string        `yaml:"log-file"`         // File the log is also appended to, without colors.
+	CPUProfile     string        `yaml:"cpuprofile"`       // File a pprof CPU profile of the run is written to.
+	MemProfile     string        `yaml:"memprofile"`       // File a pprof heap profile is written to when the run ends.
+	Retries        int           `yaml:"retries"`          // Extra attempts for reads and writes failing with transient errors, e.g. on network shares.
+	FileTimeout    time.Duration `yaml:"file-timeout"`     // Longest a single file may take to read and convert before it fails, 0 for no limit.
+	IOThreads      int           `yaml:"io-threads"`       // Files read or written concurrently, 0 to only bound them by Threads.
+	CPUThreads     int           `yaml:"cpu-threads"`      // Files compressed or decompressed concurrently, 0 to only bound them by Threads.
+	Threads        int           `yaml:"threads"`          // Number of files converted or verified concurrently, 0 means runtime.NumCPU().
+	Store          bool          `yaml:"store"`            // Store files uncompressed instead of using LZ4.
+	MinRatio       float64       `yaml:"min-ratio"`        // Compressed-to-original size ratio above which files are stored uncompressed, 0 to always use LZ4.
+	Level          int           `yaml:"level"`            // Compression level, 0 for fast LZ4 and 1-12 for LZ4 high compression.
+	Acceleration   int           `yaml:"acceleration"`     // Acceleration of the fast LZ4 compressor, 0 or 1 for the default and higher values for faster, larger output.
+	Quick          bool          `yaml:"quick"`            // Verify only the footer and CRC32 without decompressing.
+	Deep           bool          `yaml:"deep"`             // Also verify DVPL data found nested inside the decompressed data of verified files.
+	Output         string        `yaml:"output"`           // Directory converted files are written to, mirroring the input tree; the archive file in pack mode.
+	OutputTemplate string        `yaml:"output-template"`  // Output path of each converted file built from {dir}, {name}, {stem} and {ext}, mirrored below Output when both are set.
+	MaxDepth       int           `yaml:"max-depth"`        // Deepest directory level to process, 1 being the input root and 0 unlimited.
+	Order          string        `yaml:"order"`            // Order files are converted in, "size-desc", "size-asc" or "name"; empty for the directory walk order.
+	FollowSymlinks bool          `yaml:"follow-symlinks"`  // Follow symbolic links while walking directories instead of ignoring them.
+	FailFast       bool          `yaml:"fail-fast"`        // Stop the whole run after the first file that fails to convert.
+	DryRun         bool          `yaml:"dry-run"`          // Convert in memory only, without writing or deleting files.
+	NoLock         bool          `yaml:"no-lock"`          // Convert files even when another run holds their lock file.
+	JSON           bool          `yaml:"json"`             // Emit one JSON object per file and a summary instead of the colored log.
+	Report         string        `yaml:"report"`           // File a JSON report with counts, failure kinds and failed paths is written to after the run.
+	JSONSummary    bool          `yaml:"json-summary"`     // Print the log to stderr and only a JSON summary of the run to stdout.
+	SkipExisting   bool          `yaml:"skip-existing"`    // Ignore files whose output already exists.
+	Overwrite      bool          `yaml:"overwrite"`        // Allow replacing existing outputs; when false they are reported as failures.
+	OverwriteNewer bool          `yaml:"-"`                // Also let decompress replace outputs newer than their .dvpl, set by an explicit -overwrite.
+	PreserveTimes  bool          `yaml:"preserve-times"`   // Copy the source modification time onto the converted file.
+	Manifest       string        `yaml:"manifest"`         // SHA-256 manifest written after converting, or read by the checksum mode.
+	CSV            string        `yaml:"csv"`              // CSV conversion log a row per converted file is appended to after the run.
+	LenientCRC     bool          `yaml:"lenient-crc"`      // Also accept footers whose CRC32 covers the original data.
+	Tolerant       bool          `yaml:"tolerant"`         // Ignore padding after the DVPL footer.
+	CompressedExt  string        `yaml:"compressed-ext"`   // Extension appended when compressing and trimmed when decompressing, ".dvpl" when empty.
+	Format         string        `yaml:"format"`           // Format the export mode writes, "lz4", "gzip" or "zstd".
+	Footer         string        `yaml:"footer"`           // Footer version written when compressing, "v1" for WoTB or "v2" with the original data CRC32.
+	SidecarHash    string        `yaml:"sidecar-hash"`     // CRC32 polynomial of the checksum file written next to compressed files, "ieee" or "castagnoli"; empty for none.
+	Since          string        `yaml:"since"`            // RFC3339 timestamp or duration; older source files are ignored.
+	Stats          bool          `yaml:"stats"`            // Print a per-extension table of the converted files after the summary.
+	MaxFileSize    string        `yaml:"max-file-size"`    // Largest source file converted, e.g. "500MB"; larger files are ignored.
+	MaxFileBytes   int64         `yaml:"-"`                // Limit parsed from MaxFileSize, 0 when every size is converted.
+	SinceTime      time.Time     `yaml:"-"`                // Cutoff parsed from Since, zero when every file is processed.
+
+	OnResult func(Result) `yaml:"-"` // Called for every file ProcessFiles handles, one call at a time.
+	Progress *Stats       `yaml:"-"` // Counts the files of a run as they are handled when set, for progress displays.
 }
 
 // DVPLFooter represents the DVPL file footer data.
-type DVPLFooter struct {
-	OriginalSize   uint32
-	CompressedSize uint32
-	CRC32          uint32
-	Type           uint32
+//
+// Deprecated: use dvpl.DVPLFooter, which dvpl.ParseFooter returns.
+type DVPLFooter = dvpl.DVPLFooter
+
+// workerCount returns how many files are worked on at once. With -io-threads or -cpu-threads set there are
+// enough workers to keep both stages busy, so some files can be read or written while others are converted.
+func (config *Config) workerCount() int {
+	if config.IOThreads < 1 && config.CPUThreads < 1 {
+		return config.Threads
+	}
+	return config.stageThreads(config.IOThreads) + config.stageThreads(config.CPUThreads)
+}
+
+// stageThreads returns how many files one stage works on at once given its -io-threads or -cpu-threads value,
+// which defaults to -threads, or to one per CPU without it.
+func (config *Config) stageThreads(threads int) int {
+	if threads > 0 {
+		return threads
+	}
+	if config.Threads > 0 {
+		return config.Threads
+	}
+	return runtime.NumCPU()
+}
+
+// compressedExt returns the extension of compressed files, defaulting to .dvpl for configs built without flags.
+func (config *Config) compressedExt() string {
+	if config.CompressedExt == "" {
+		return dvplExtension
+	}
+	return config.CompressedExt
+}
+
+// isCompressed reports whether path ends in the compressed extension, in any letter case, so
+// files named .DVPL on case-insensitive file systems are recognized too.
+func (config *Config) isCompressed(path string) bool {
+	ext := config.compressedExt()
+	return len(path) >= len(ext) && strings.EqualFold(path[len(path)-len(ext):], ext)
+}
+
+// trimCompressedExt removes the compressed extension from path, whatever its letter case.
+func (config *Config) trimCompressedExt(path string) string {
+	if !config.isCompressed(path) {
+		return path
+	}
+	return path[:len(path)-len(config.compressedExt())]
+}
+
+// FormatSize formats a byte count using binary units, e.g. "410 KB" or "1.2 MB".
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	suffix := ""
+	for _, suffix = range suffixes {
+		value /= unit
+		if value < unit {
+			break
+		}
+	}
+
+	if value < 10 {
+		return fmt.Sprintf("%.1f %s", value, suffix)
+	}
+	return fmt.Sprintf("%.0f %s", value, suffix)
 }
 
 func PrintElapsedTime(elapsedTime time.Duration) {
@@ -44,15 +173,15 @@ func PrintElapsedTime(elapsedTime time.Duration) {
 	switch {
 	case elapsedTime.Seconds() < 1:
 		colorCode = colors.GreenColor // Milliseconds
-		fmt.Printf("\nProcessing took %s%d ms%s\n\n", colorCode, int(elapsedTime.Round(time.Millisecond).Milliseconds()), colors.ResetColor)
+		fmt.Fprintf(Output, "\nProcessing took %s%d ms%s\n\n", colorCode, int(elapsedTime.Round(time.Millisecond).Milliseconds()), colors.ResetColor)
 		return
 	case elapsedTime.Minutes() < 1:
 		colorCode = colors.YellowColor // Seconds
-		fmt.Printf("\nProcessing took %s%d s%s\n\n", colorCode, int(elapsedTime.Round(time.Second).Seconds()), colors.ResetColor)
+		fmt.Fprintf(Output, "\nProcessing took %s%d s%s\n\n", colorCode, int(elapsedTime.Round(time.Second).Seconds()), colors.ResetColor)
 		return
 	default:
 		colorCode = colors.RedColor // Minutes
-		fmt.Printf("\nProcessing took %s%d min%s\n\n", colorCode, int(elapsedTime.Round(time.Minute).Minutes()), colors.ResetColor)
+		fmt.Fprintf(Output, "\nProcessing took %s%d min%s\n\n", colorCode, int(elapsedTime.Round(time.Minute).Minutes()), colors.ResetColor)
 		return
 	}
 }
@@ -62,23 +191,256 @@ func ParseCommandLineArgs() (*Config, error) {
 	config := &Config{}
 	flag.StringVar(&config.Mode, "mode", "", "Mode can be 'compress' / 'decompress' / 'help' (for an extended help guide).")
 	flag.BoolVar(&config.KeepOriginals, "keep-originals", false, "Keep original files after compression/decompression.")
+	flag.BoolVar(&config.PruneEmpty, "prune-empty", false, "Remove directories left empty once their original files were deleted, never the input directory itself.")
 	flag.StringVar(&config.Path, "path", "", "directory/files path to process. Default is the current directory.")
+	flag.StringVar(&config.FromFile, "from-file", "", "File listing the files to convert, one path per line ('#' starts a comment), instead of walking a directory.")
 	flag.StringVar(&config.Ignore, "ignore", "", "Comma-separated list of file extensions to ignore during compression.")
+	flag.StringVar(&config.Include, "include", "", "Comma-separated list of glob patterns (e.g. '*.yaml,*.json'), only matching files are processed.")
+	flag.BoolVar(&config.SkipHidden, "skip-hidden", false, "Skip files and directories whose names start with a dot, such as .git, without looking inside them.")
+	flag.StringVar(&config.ExcludeDir, "exclude-dir", "", "Comma-separated list of directory names or glob patterns (e.g. 'backup,.git') whose whole subtree is skipped.")
 	flag.BoolVar(&config.Verbose, "verbose", false, "Run in verbose mode (prints detailed log messages).")
+	flag.StringVar(&config.LogFile, "log-file", "", "Also append the log to this file, without color codes.")
+	flag.StringVar(&config.CPUProfile, "cpuprofile", "", "Write a pprof CPU profile of the run to this file.")
+	flag.StringVar(&config.MemProfile, "memprofile", "", "Write a pprof heap profile to this file when the run ends.")
+	flag.BoolVar(&config.Quiet, "quiet", false, "Print only the final summary line (no banner, per-file messages or timing).")
+	flag.BoolVar(&config.QuietOnSuccess, "quiet-on-success", false, "Verify mode prints nothing when every file is valid, and only the failed files and summary otherwise.")
+	flag.IntVar(&config.Level, "level", 0, "Compression level: 0 is fast LZ4, 1-12 use LZ4 high compression (slower, smaller files).")
+	flag.IntVar(&config.Acceleration, "acceleration", 1, "Acceleration of the fast LZ4 compressor: 0 and 1 both select the default, higher values compress faster into larger files.")
+	flag.Float64Var(&config.MinRatio, "min-ratio", 0, "Store files uncompressed when LZ4 output is above this fraction of their size (e.g. 0.95). Default 0 always uses LZ4.")
+	flag.BoolVar(&config.Store, "store", false, "Store files uncompressed inside the dvpl container instead of using LZ4.")
+	flag.IntVar(&config.MaxDepth, "max-depth", 0, "Maximum directory depth to process, 1 only processes the top-level files. Default 0 is unlimited.")
+	flag.StringVar(&config.Order, "order", "", "Order to convert files in: 'size-desc', 'size-asc' or 'name'. Default is the directory walk order, which starts converting sooner.")
+	flag.BoolVar(&config.FollowSymlinks, "follow-symlinks", false, "Follow symbolic links while walking directories instead of ignoring them.")
+	flag.BoolVar(&config.FailFast, "fail-fast", false, "Stop after the first file that fails to convert instead of continuing with the rest.")
+	flag.StringVar(&config.Output, "output", "", "Directory to write converted files into, preserving the input directory structure. Pack mode writes the archive file to it.")
+	flag.BoolVar(&config.NoLock, "no-lock", false, "Do not lock files while converting them, so files another run is converting are not skipped.")
+	flag.StringVar(&config.OutputTemplate, "output-template", "", "Output path of each converted file, e.g. '{dir}/extracted/{name}'. Tokens: {dir}, {name}, {stem} and {ext}.")
+	flag.BoolVar(&config.DryRun, "dry-run", false, "Convert files in memory and report the results without writing or deleting anything.")
+	flag.BoolVar(&config.JSON, "json", false, "Print one JSON object per file and a final summary object instead of the colored log (compress/decompress).")
+	flag.StringVar(&config.Report, "report", "", "Write a JSON report with counts, elapsed time, failure kinds and failed paths to this file after the run.")
+	flag.BoolVar(&config.JSONSummary, "json-summary", false, "Print the log to stderr and only a final JSON summary object to stdout, for wrapper scripts.")
+	flag.BoolVar(&config.SkipExisting, "skip-existing", false, "Skip files whose converted output already exists.")
+	flag.BoolVar(&config.Overwrite, "overwrite", true, "Overwrite existing outputs. Use -overwrite=false to report them as failures instead, or -overwrite to also replace decompressed files edited since extraction.")
+	flag.BoolVar(&config.PreserveTimes, "preserve-times", true, "Copy the source modification time onto converted files. Use -preserve-times=false for fresh timestamps.")
+	flag.StringVar(&config.CSV, "csv", "", "Append a row per converted file with its paths, sizes, footer CRC32 and timestamp to this CSV file.")
+	flag.StringVar(&config.Manifest, "manifest", "", "Write a SHA-256 manifest of the converted files to this path, or the manifest to check in checksum mode.")
+	flag.BoolVar(&config.LenientCRC, "lenient-crc", false, "Also accept dvpl files whose footer CRC32 covers the original data instead of the compressed block.")
+	flag.BoolVar(&config.Tolerant, "tolerant", false, "Accept dvpl files padded with extra bytes after the footer.")
+	flag.StringVar(&config.CompressedExt, "compressed-ext", dvplExtension, "Extension appended to compressed files and trimmed from them on decompression.")
+	flag.StringVar(&config.Format, "format", "lz4", "Format written by the export mode: 'lz4', 'gzip' or 'zstd'.")
+	flag.StringVar(&config.Footer, "footer", "v1", "Footer written when compressing: 'v1' is read by WoTB, 'v2' also stores the CRC32 of the original data but cannot be read by the game.")
+	flag.StringVar(&config.SidecarHash, "sidecar-hash", "", "Write a .crc checksum file next to every compressed file using the 'ieee' or 'castagnoli' CRC32. The footer CRC32 stays IEEE.")
+	flag.StringVar(&config.Since, "since", "", "Only process files modified after this RFC3339 timestamp (e.g. 2024-04-01T00:00:00Z) or within this duration (e.g. 24h).")
+	flag.StringVar(&config.MaxFileSize, "max-file-size", "", "Skip files larger than this size (e.g. 500MB or 2GB) instead of loading them into memory.")
+	flag.BoolVar(&config.Stats, "stats", false, "Print a table of file counts and sizes per extension, sorted by bytes saved, after converting.")
+	flag.BoolVar(&config.Deep, "deep", false, "Verify mode also checks DVPL data nested inside the decompressed files, reporting how deep it goes.")
+	flag.BoolVar(&config.Quick, "quick", false, "Verify only the footer and CRC32 checksum without decompressing.")
+	flag.IntVar(&config.Retries, "retries", 0, "Retry reads and writes failing with transient errors (timeouts, busy files) up to this many times, for network shares.")
+	flag.DurationVar(&config.FileTimeout, "file-timeout", 0, "Fail files that take longer than this to read and convert (e.g. 30s) and continue with the rest. Default 0 is no limit.")
+	flag.IntVar(&config.IOThreads, "io-threads", 0, "Number of files read or written concurrently, for tuning slow network mounts together with -cpu-threads.")
+	flag.IntVar(&config.CPUThreads, "cpu-threads", 0, "Number of files compressed or decompressed concurrently, for tuning together with -io-threads.")
+	flag.IntVar(&config.Threads, "threads", runtime.NumCPU(), "Number of files to convert or verify concurrently. Use 1 for serial processing.")
+
+	var configFile string
+	flag.StringVar(&configFile, "config", "", "YAML or JSON file with default flag values. Flags given on the command line override it.")
 
 	flag.Parse()
 
+	if configFile != "" {
+		if err := loadConfigFile(configFile, config); err != nil {
+			return nil, err
+		}
+	}
+
+	// Decompressed files edited since they were extracted are only replaced when asked for explicitly
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "overwrite" {
+			config.OverwriteNewer = config.Overwrite
+		}
+	})
+
 	if config.Mode == "" {
 		return nil, errors.New("no mode selected. Use '-help' for usage information")
 	}
 
-	// Check if the -path flag was provided
-	if config.Path == "" {
+	if config.Quiet && config.Verbose {
+		return nil, errors.New("-quiet and -verbose cannot be combined")
+	}
+
+	if config.QuietOnSuccess && config.Verbose {
+		return nil, errors.New("-quiet-on-success and -verbose cannot be combined")
+	}
+
+	if config.QuietOnSuccess && config.Mode != "verify" {
+		return nil, errors.New("-quiet-on-success is only supported by the verify mode")
+	}
+
+	if !strings.HasPrefix(config.CompressedExt, ".") || len(config.CompressedExt) < 2 || strings.ContainsAny(config.CompressedExt, `/\`) {
+		return nil, fmt.Errorf("invalid compressed extension %q, expected a dot followed by a name such as .dvpl", config.CompressedExt)
+	}
+
+	if _, ok := fileOrders[config.Order]; config.Order != "" && !ok {
+		return nil, fmt.Errorf("invalid order %q, expected size-desc, size-asc or name", config.Order)
+	}
+
+	if _, ok := exportFormats[config.Format]; !ok {
+		return nil, fmt.Errorf("invalid format %q, expected lz4, gzip or zstd", config.Format)
+	}
+
+	if config.Footer != "v1" && config.Footer != "v2" {
+		return nil, fmt.Errorf("invalid footer %q, expected v1 or v2", config.Footer)
+	}
+
+	if config.MinRatio < 0 || config.MinRatio > 1 {
+		return nil, fmt.Errorf("invalid min ratio %g, expected 0-1", config.MinRatio)
+	}
+
+	if config.Level < 0 || config.Level > dvpl.MaxLevel {
+		return nil, fmt.Errorf("invalid compression level %d, expected 0-%d", config.Level, dvpl.MaxLevel)
+	}
+
+	if config.Acceleration < 0 || config.Acceleration > dvpl.MaxAcceleration {
+		return nil, fmt.Errorf("invalid acceleration %d, expected 0-%d, where 0 and 1 both select the default compressor", config.Acceleration, dvpl.MaxAcceleration)
+	}
+	if config.Acceleration > 1 && config.Level > 0 {
+		return nil, errors.New("-acceleration only applies to the fast compressor of -level 0")
+	}
+
+	if config.CSV != "" && !convertsFiles(config.Mode) {
+		return nil, errors.New("-csv is only supported by the compress, decompress and auto modes")
+	}
+
+	if config.Mode == "checksum" && config.Manifest == "" {
+		return nil, errors.New("checksum mode requires -manifest")
+	}
+
+	if config.Mode == "pack" && config.Output == "" {
+		return nil, errors.New("pack mode requires -output")
+	}
+
+	if config.Since != "" {
+		sinceTime, err := parseSince(config.Since, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		config.SinceTime = sinceTime
+	}
+
+	if config.MaxFileSize != "" {
+		maxFileBytes, err := parseSize(config.MaxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		config.MaxFileBytes = maxFileBytes
+	}
+
+	if config.IOThreads < 0 || config.CPUThreads < 0 {
+		return nil, errors.New("-io-threads and -cpu-threads cannot be negative")
+	}
+
+	if config.Retries < 0 {
+		return nil, fmt.Errorf("invalid retries %d, expected 0 or more", config.Retries)
+	}
+
+	if config.MaxDepth < 0 {
+		return nil, fmt.Errorf("invalid max depth %d, expected 0 or more", config.MaxDepth)
+	}
+
+	// Reject malformed include patterns up front instead of silently matching nothing
+	if config.Include != "" {
+		for _, pattern := range strings.Split(config.Include, ",") {
+			if _, err := filepath.Match(strings.TrimSpace(pattern), ""); err != nil {
+				return nil, fmt.Errorf("invalid include pattern %q: %v", pattern, err)
+			}
+		}
+	}
+
+	if config.ExcludeDir != "" {
+		for _, pattern := range strings.Split(config.ExcludeDir, ",") {
+			if _, err := filepath.Match(strings.TrimSpace(pattern), ""); err != nil {
+				return nil, fmt.Errorf("invalid exclude-dir pattern %q: %v", pattern, err)
+			}
+		}
+	}
+
+	if config.PruneEmpty && !convertsFiles(config.Mode) {
+		return nil, errors.New("-prune-empty is only supported by the compress, decompress and auto modes")
+	}
+
+	if config.SidecarHash != "" {
+		if config.Mode != "compress" && config.Mode != "auto" {
+			return nil, errors.New("-sidecar-hash is only supported by the compress and auto modes")
+		}
+		if _, ok := sidecarTables[config.SidecarHash]; !ok {
+			return nil, fmt.Errorf("invalid sidecar hash %q, expected ieee or castagnoli", config.SidecarHash)
+		}
+	}
+
+	if config.Deep && config.Mode != "verify" {
+		return nil, errors.New("-deep is only supported by the verify mode")
+	}
+
+	if config.Deep && config.Quick {
+		return nil, errors.New("-deep and -quick cannot be combined")
+	}
+
+	if config.OutputTemplate != "" {
+		if !convertsFiles(config.Mode) {
+			return nil, errors.New("-output-template is only supported by the compress, decompress and auto modes")
+		}
+		if err := validateOutputTemplate(config.OutputTemplate); err != nil {
+			return nil, fmt.Errorf("invalid output template %q: %v", config.OutputTemplate, err)
+		}
+	}
+
+	if config.Report != "" && !reportsResults(config.Mode) {
+		return nil, fmt.Errorf("-report is not supported by the %s mode", config.Mode)
+	}
+
+	if config.JSONSummary && !reportsResults(config.Mode) {
+		return nil, fmt.Errorf("-json-summary is not supported by the %s mode", config.Mode)
+	}
+
+	if config.JSONSummary && config.JSON {
+		return nil, errors.New("-json-summary and -json cannot be combined")
+	}
+
+	// A file list replaces the paths entirely, so nothing defaults to the current directory
+	if config.FromFile != "" {
+		if !convertsFiles(config.Mode) {
+			return nil, errors.New("-from-file is only supported by the compress, decompress and auto modes")
+		}
+		if config.Path != "" || flag.NArg() > 0 {
+			return nil, errors.New("-from-file cannot be combined with -path or path arguments")
+		}
+		paths, err := ReadFileList(config.FromFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Paths = paths
+		return config, nil
+	}
+
+	// Trailing arguments are extra paths to process
+	if config.Path != "" {
+		config.Paths = append(config.Paths, config.Path)
+	}
+	config.Paths = append(config.Paths, flag.Args()...)
+
+	// Check if any path was provided
+	if len(config.Paths) == 0 {
 		// If not, set the path to the current directory
 		if initialPath, err := os.Getwd(); err == nil {
-			config.Path = initialPath
+			config.Paths = append(config.Paths, initialPath)
 		}
 	}
+	if len(config.Paths) > 0 {
+		config.Path = config.Paths[0]
+	}
+
+	if len(config.Paths) > 1 && !convertsFiles(config.Mode) {
+		return nil, errors.New("multiple paths are only supported by the compress, decompress and auto modes")
+	}
 
 	// Set the global variable to the value of config.Path
 	GlobalPath = config.Path
@@ -86,24 +448,164 @@ func ParseCommandLineArgs() (*Config, error) {
 	return config, nil
 }
 
+// parseSince turns a -since value into a cutoff time: either an RFC3339 timestamp,
+// or a duration counted back from now.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if cutoff, err := time.Parse(time.RFC3339, since); err == nil {
+		return cutoff, nil
+	}
+	if duration, err := time.ParseDuration(since); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q, expected an RFC3339 timestamp or a duration such as 24h", since)
+}
+
+// sizeUnits are the -max-file-size suffixes, binary like FormatSize. Longer suffixes come first so "MB" is not read as "B".
+var sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// parseSize turns a size such as "500MB", "1.5GB" or "4096" into a positive number of bytes.
+func parseSize(size string) (int64, error) {
+	number := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(number, unit.suffix) {
+			number = strings.TrimSpace(strings.TrimSuffix(number, unit.suffix))
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	bytes := value * multiplier
+	if err != nil || !(bytes >= 1) || bytes > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q, expected a positive number with an optional unit such as 500MB", size)
+	}
+	return int64(bytes), nil
+}
+
+// loadConfigFile fills config from a YAML or JSON file whose keys are flag names,
+// then reapplies the flags given on the command line so they take precedence.
+func loadConfigFile(path string, config *Config) error {
+	explicit := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	// JSON is valid YAML, so one decoder handles both formats
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	for name, value := range explicit {
+		if err := flag.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func PrintHelpMessage() {
-	fmt.Println(`dvpl_lz4 [-mode] [-keep-originals] [-path]
+	fmt.Fprintln(Output, `dvpl_lz4 [-mode] [-keep-originals] [-path]
 
     • mode can be one of the following:
 
         compress: compresses files into dvpl.
         decompress: decompresses dvpl files into standard files.
 		verify: verify compressed dvpl files to determine valid compression.
+		repair: rewrite the footers of dvpl files whose block is intact but whose footer records wrong sizes or a wrong CRC32, in place.
+		info: print the footer metadata of dvpl files without decompressing them.
+		compare: decompress dvpl files and byte-compare them against their original files.
+		checksum: re-hash the files listed in a -manifest and report any that changed.
+		benchmark: compress files in memory only and report throughput, average ratio and per-file timings.
+		autotune: compress a sample of the files in memory at several thread counts and recommend the -threads value with the best throughput.
+		list: print the files that compress/decompress would convert, honoring -ignore and -include, without reading them.
+		auto: compress the files without the .dvpl extension and decompress the .dvpl files in a single pass, skipping files whose counterpart also exists.
+		count: print only how many files compress/decompress would convert or ignore and their total size, without reading them, as a quick preflight.
+		pack: compress the files of a directory into a single .dvplpack archive given by -output.
+		unpack: restore the files of a .dvplpack archive into -output, or next to the archive, checking every CRC32.
+		export-lz4: write the data of dvpl files as standard .lz4 frame files for other lz4 tools, leaving the dvpl files untouched.
+		export: like export-lz4, in the -format given: lz4 (.lz4), gzip (.gz) or zstd (.zst) for archives and non-game tools.
+		raw-extract: write the raw LZ4 block of dvpl files without the footer to .lz4block files, with the original size in a .lz4block.size sidecar.
+		raw-wrap: build dvpl files from .lz4block files and their .lz4block.size sidecars, the reverse of raw-extract.
+		doctor: check that compressing, decompressing and verifying work on this machine, and print the Go, OS and LZ4 versions for bug reports.
 		gui: opens the graphical user interface window.
         help: show this help message.
 
 	• flags can be one of the following:
 
     	-keep-originals flag keeps the original files after compression/decompression.
+		-prune-empty removes the directories below the input that were left empty once their original files were deleted, e.g. with -output-template. The input directory itself and directories holding anything else are kept.
+		-config loads flag values from a YAML or JSON file keyed by flag name. Flags on the command line override it.
 		-path specifies the directory/files path to process. Default is the current directory.
+		  Further files or directories can be listed after the flags to compress/decompress them in one run.
+		-from-file converts exactly the paths listed in a file, one per line, instead of walking -path. Lines starting with # are comments.
 		-ignore specifies comma-separated file extensions to ignore during compression.
+		-include specifies comma-separated glob patterns, only files whose name matches one are processed.
+		-exclude-dir specifies comma-separated directory names or glob patterns (e.g. backup,.git) whose whole subtree is skipped.
+		-skip-hidden skips files and directories whose names start with a dot, such as .hidden.yaml or .git, without looking inside them.
+		-max-depth limits how deep directories are walked, 1 only processes the top-level files. Deeper files are ignored.
+		-follow-symlinks follows symbolic links inside directories. By default they are ignored.
+		-fail-fast stops the whole run after the first file that fails to convert.
+		-output specifies a directory to write converted files into instead of next to the originals. Pack mode writes the archive to it.
+		-output-template names each converted file from the tokens {dir} (its directory), {name} (the usual output name), {stem} and {ext} (that name without and with only its extension). With -output the expanded path is mirrored below the output directory, so it must stay inside the input tree.
+		-dry-run converts files in memory only and reports what would change without writing or deleting files.
+		-no-lock converts files without creating their .dvpl_lz4.lock file, which otherwise makes other runs on the same directory skip them; also use it after a killed run left lock files behind.
+		-json prints one JSON object per file and a final summary object to stdout (compress/decompress).
+		-report writes a JSON file with the counts, elapsed time, failures per kind and failed paths once the run is over.
+		-json-summary prints the banner, per-file lines and summary to stderr and only the same JSON summary as -report to stdout, so wrappers can discard the log with 2>/dev/null.
+		-skip-existing skips files whose converted output already exists, making interrupted runs resumable.
+		-since only processes files modified after an RFC3339 timestamp or within a duration such as 24h. Older files are ignored.
+		-max-file-size skips files larger than a size such as 500MB or 2GB, since every file is loaded into memory.
+		-overwrite=false refuses to replace existing outputs and reports them as failures.
+		-overwrite given explicitly also lets decompress replace files modified after their .dvpl, which are otherwise skipped with a warning.
+		-preserve-times=false gives converted files a fresh timestamp instead of the source modification time.
+		-level sets the compression level: 0 is fast LZ4 (default), 1-12 use LZ4 high compression.
+		-acceleration speeds up the fast LZ4 compressor like LZ4_compress_fast: 0 and 1 (default) both keep today's output, higher values compress faster into larger files.
+		-store keeps file data uncompressed inside the dvpl container (type 0) during compression.
+		-min-ratio stores files uncompressed when LZ4 only shrinks them to more than this fraction of their size, e.g. 0.95.
+		-manifest writes a SHA-256 manifest of the converted files (compress/decompress), or names the manifest to check (checksum).
+		-csv appends a row per converted file (compress/decompress/auto) with the source and output paths, sizes, footer CRC32 and timestamp to a CSV file.
+		-lenient-crc also accepts dvpl variants whose footer CRC32 covers the original data. WoTB files are checked strictly by default.
+		-tolerant accepts dvpl files that were padded with up to 4 KB of extra bytes after the footer.
+		-compressed-ext sets the extension compressed files get and decompress looks for. Default is .dvpl.
+		-footer v2 also stores the CRC32 of the original data, which decompress and verify check. WoTB cannot read v2 files.
+		-sidecar-hash writes a <file>.dvpl.crc file next to every compressed file holding its CRC32 with the ieee or castagnoli (CRC-32C) polynomial, for checking with other tools. The footer CRC32 stays IEEE so the game can read the files.
+		-format sets what the export mode writes: lz4 (default), gzip or zstd.
+		-quick makes verify check only the footer and CRC32 checksum without decompressing.
+		-deep makes verify also decompress and check DVPL data nested inside the decompressed data, up to 16 levels deep, and report the nesting depth of each file.
+		Verify also accepts a .zip file as -path and checks the .dvpl files inside it in memory, without extracting them.
+		-threads specifies how many files are converted or verified concurrently. Default is the number of CPUs.
+		-io-threads and -cpu-threads separately limit how many files are read or written and how many are converted at once, e.g. for network mounts.
+		-order converts files largest first (size-desc), smallest first (size-asc) or sorted by path (name). The whole tree is listed before anything is converted. By default files are converted as they are found.
+		-retries retries reads and writes that fail with transient errors, such as timeouts on network shares, up to this many times.
+		-file-timeout fails any file that takes longer than this duration (e.g. 30s) to read and convert, so a pathological file cannot stall an unattended run. The other files are still converted.
+		-quiet prints only the final summary line, without the banner, per-file messages or timing.
+		-quiet-on-success makes verify print nothing when every file is valid, and only the failed files and summary otherwise.
+		-log-file also appends the log to a file, without color codes. Colors are disabled when output is not a terminal or NO_COLOR is set.
+		-cpuprofile and -memprofile write pprof CPU and heap profiles of the run, for inspecting with go tool pprof.
+		-verbose prints every processed file, and after compress/decompress the bytes and time spent reading, converting and writing.
+		-stats prints file counts and sizes per extension after compress/decompress, sorted by bytes saved.
 		-silent disables all file processing verbose information
 
+	• exit codes:
+
+		0: every file was processed successfully.
+		1: at least one file failed to convert, verify or compare, or the run itself failed.
+		2: invalid command-line arguments or mode.
+
 	• usage can be one of the following examples:
 
 		$ dvpl_lz4 -mode help
@@ -128,219 +630,394 @@ func PrintHelpMessage() {
 		
 		$ dvpl_lz4 -mode dcompress -keep-originals -path /path/to/decompress/compress.yaml
 
+		$ dvpl_lz4 -mode compress /path/to/first.yaml /path/to/second/directory
+
+		$ dvpl_lz4 -mode auto -keep-originals=false -path /path/to/mod
+
+		$ dvpl_lz4 -config /path/to/dvpl_lz4.yaml -keep-originals=false
+
 		$ dvpl_lz4 -mode compress -path /path/to/decompress -ignore .exe,.dll
 
+		$ dvpl_lz4 -mode compress -path /path/to/decompress -include "*.yaml,*.json"
+
+		$ dvpl_lz4 -mode compress -path /path/to/decompress -max-depth 1
+
+		$ dvpl_lz4 -mode compress -path /path/to/game/Data -output /path/to/output
+
+		$ dvpl_lz4 -mode decompress -output-template "{dir}/extracted/{name}" -path /path/to/compress
+
+		$ dvpl_lz4 -mode compress -dry-run -verbose -path /path/to/decompress
+
+		$ dvpl_lz4 -mode compress -json -path /path/to/decompress
+
+		$ dvpl_lz4 -mode compress -skip-existing -path /path/to/decompress
+
+		$ dvpl_lz4 -mode compress -level 9 -path /path/to/decompress
+
+		$ dvpl_lz4 -mode compress -acceleration 8 -path /path/to/decompress
+
+		$ dvpl_lz4 -mode compress -store -path /path/to/decompress/compress.png
+
 		$ dvpl_lz4 -mode verify -path /path/to/verify/compress.yaml.dvpl
 
 		$ dvpl_lz4 -mode verify -path /path/to/verify/
 
+		$ dvpl_lz4 -mode verify -quick -path /path/to/verify/
+
+		$ dvpl_lz4 -mode verify -deep -verbose -path /path/to/verify/
+
+		$ dvpl_lz4 -mode verify -verbose -path /path/to/mod.zip
+
+		$ dvpl_lz4 -mode decompress -lenient-crc -path /path/to/decompress
+
+		$ dvpl_lz4 -mode repair -verbose -path /path/to/broken
+
+		$ dvpl_lz4 -mode compare -path /path/to/compare/compress.yaml
+
+		$ dvpl_lz4 -mode compare -verbose -path /path/to/compare/
+
+		$ dvpl_lz4 -mode compress -manifest /path/to/manifest.txt -path /path/to/decompress
+
+		$ dvpl_lz4 -mode checksum -manifest /path/to/manifest.txt
+
+		$ dvpl_lz4 -mode compress -csv /path/to/conversions.csv -path /path/to/decompress
+
+		$ dvpl_lz4 -mode list -include "*.yaml" -path /path/to/game/Data
+
+		$ dvpl_lz4 -mode count -path /path/to/game/Data
+
+		$ dvpl_lz4 -mode benchmark -threads 4 -level 9 -path /path/to/game/Data
+
+		$ dvpl_lz4 -mode autotune -level 9 -path /path/to/game/Data
+
+		$ dvpl_lz4 -mode info -path /path/to/inspect/compress.yaml.dvpl
+
 		$ dvpl_lz4 -mode dcompress -silent
 
-	`)
-}
+		$ dvpl_lz4 -mode verify -quiet -path /path/to/verify/
 
-// ProcessFiles process files in the directory or file specified in the config.
-func ProcessFiles(directoryOrFile string, config *Config) (successCount, failureCount, ignoredCount int, err error) {
-	// Initialize counters
-	successCount = 0
-	failureCount = 0
-	ignoredCount = 0
+		$ dvpl_lz4 -mode verify -quiet-on-success -path /path/to/game/Data
 
-	info, err := os.Stat(directoryOrFile)
-	if err != nil {
-		return 0, 0, 0, err
-	}
+		$ dvpl_lz4 -mode compress -verbose -log-file /path/to/dvpl_lz4.log -path /path/to/decompress
 
-	// Get the path of the currently running executable
-	executablePath, err := os.Executable()
-	if err != nil {
-		return 0, 0, 0, err
-	}
+		$ dvpl_lz4 -mode compress -threads 1 -path /path/to/decompress
 
-	if info.IsDir() {
-		dirList, err := os.ReadDir(directoryOrFile)
-		if err != nil {
-			return 0, 0, 0, err
-		}
+		$ dvpl_lz4 -mode compress -order size-desc -path /path/to/decompress
 
-		for _, dirItem := range dirList {
-			succ, fail, ignored, err := ProcessFiles(filepath.Join(directoryOrFile, dirItem.Name()), config)
-			if err != nil {
-				if config.Verbose {
-					fmt.Printf("\n%sError%s processing directory %s: %v\n", colors.RedColor, colors.ResetColor, dirItem.Name(), err)
-				}
-			}
-			successCount += succ
-			failureCount += fail
-			ignoredCount += ignored
-		}
-	} else {
-		// Check if the file is the executable itself
-		if directoryOrFile == executablePath {
-			if config.Verbose {
-				fmt.Printf("\n%sIgnoring%s own executable file %s\n", colors.YellowColor, colors.ResetColor, directoryOrFile)
-			}
-			ignoredCount++
-			return successCount, failureCount, ignoredCount, nil
-		}
+		$ dvpl_lz4 -mode compress -json-summary -path /path/to/decompress 2>/dev/null
 
-		isDecompression := config.Mode == "decompress" && strings.HasSuffix(directoryOrFile, dvplExtension)
-		isCompression := config.Mode == "compress" && !strings.HasSuffix(directoryOrFile, dvplExtension)
+		$ dvpl_lz4 -mode pack -path /path/to/game/Data -output /path/to/Data.dvplpack
 
-		ignoreExtensions := make(map[string]bool)
-		if config.Ignore != "" {
-			extensions := strings.Split(config.Ignore, ",")
-			for _, ext := range extensions {
-				ignoreExtensions[ext] = true
-			}
-		}
+		$ dvpl_lz4 -mode unpack -path /path/to/Data.dvplpack -output /path/to/restored
 
-		shouldIgnore := ignoreExtensions[filepath.Ext(directoryOrFile)]
+		$ dvpl_lz4 -mode export-lz4 -path /path/to/inspect/compress.yaml.dvpl
 
-		if !shouldIgnore && (isDecompression || isCompression) {
-			filePath := directoryOrFile
-			fileData, err := os.ReadFile(filePath)
-			if err != nil {
-				if config.Verbose {
-					fmt.Printf("\n%sError%s reading file %s: %v\n", colors.RedColor, colors.ResetColor, directoryOrFile, err)
-				}
-				return 0, 0, 0, err
-			}
+		$ dvpl_lz4 -mode export -format gzip -output /path/to/archive -path /path/to/game/Data
 
-			var processedBlock []byte
-			newName := ""
+		$ dvpl_lz4 -mode raw-extract -path /path/to/inspect/compress.yaml.dvpl
 
-			if isCompression {
-				processedBlock, err = dvpl.CompressDVPL(fileData)
-				newName = directoryOrFile + dvplExtension
-			} else {
-				processedBlock, err = dvpl.DecompressDVPL(fileData)
-				newName = strings.TrimSuffix(directoryOrFile, dvplExtension)
-			}
+		$ dvpl_lz4 -mode doctor
 
-			if err != nil {
-				if config.Verbose {
-					fmt.Printf("\n%sFile%s %s %sfailed to convert due to %v%s\n", colors.RedColor, colors.ResetColor, directoryOrFile, colors.RedColor, err, colors.ResetColor)
-				}
-				return 0, 1, 0, nil // Return failure count as 1 for this file
-			}
+		$ dvpl_lz4 -mode compress -keep-originals -skip-existing -since 24h -path /path/to/game/Data
 
-			err = os.WriteFile(newName, processedBlock, 0644)
-			if err != nil {
-				if config.Verbose {
-					fmt.Printf("\n%sError%s writing file %s: %v\n", colors.RedColor, colors.ResetColor, newName, err)
-				}
-				return 0, 0, 0, err
-			}
+		$ dvpl_lz4 -mode compress -keep-originals -from-file /path/to/changed-files.txt
 
-			if config.Verbose {
-				fmt.Printf("\n%sFile%s %s has been successfully %s into %s%s%s\n", colors.GreenColor, colors.ResetColor, filePath, getAction(config.Mode), colors.GreenColor, newName, colors.ResetColor)
-			}
+		$ dvpl_lz4 -mode compress -footer v2 -output /path/to/archive -path /path/to/game/Data
 
-			if !config.KeepOriginals {
-				err := os.Remove(filePath)
-				if err != nil {
-					if config.Verbose {
-						fmt.Printf("\n%sError%s deleting file %s: %v\n", colors.RedColor, colors.ResetColor, filePath, err)
-					}
-				}
-			}
+		$ dvpl_lz4 -mode compress -sidecar-hash castagnoli -path /path/to/decompress
 
-			successCount++
-		} else {
-			if config.Verbose {
-				fmt.Printf("\n%sIgnoring%s file %s\n", colors.YellowColor, colors.ResetColor, directoryOrFile)
-			}
-			ignoredCount++
-		}
+	`)
+}
+
+// trimPadding drops trailing padding after the DVPL footer when the config is tolerant of it.
+func trimPadding(buffer []byte, config *Config) []byte {
+	if config.Tolerant {
+		return dvpl.TrimDVPLPadding(buffer)
 	}
+	return buffer
+}
 
-	return successCount, failureCount, ignoredCount, nil
+// decompressDVPL decompresses a DVPL buffer using the padding and CRC32 semantics selected in the config.
+func decompressDVPL(buffer []byte, config *Config) ([]byte, error) {
+	buffer = trimPadding(buffer, config)
+	if config.LenientCRC {
+		return dvpl.DecompressDVPLLenient(buffer)
+	}
+	return dvpl.DecompressDVPL(buffer)
+}
+
+// convertsFiles reports whether mode compresses or decompresses files in place, like -mode auto does file by file.
+func convertsFiles(mode string) bool {
+	return mode == "compress" || mode == "decompress" || mode == "auto"
 }
 
-func getAction(mode string) string {
-	if mode == "compress" {
+// reportsResults reports whether mode returns per-file results, which -report and -json-summary sum up.
+func reportsResults(mode string) bool {
+	switch mode {
+	case "compress", "decompress", "auto", "verify", "repair", "list", "count", "export", "export-lz4", "raw-extract", "raw-wrap", "pack", "unpack":
+		return true
+	}
+	return false
+}
+
+// GetAction returns the colored past-tense verb describing what the mode does to a file.
+func GetAction(mode string) string {
+	switch mode {
+	case "compress":
 		return colors.GreenColor + "compressed" + colors.ResetColor
+	case "pack":
+		return colors.GreenColor + "packed" + colors.ResetColor
+	case "unpack":
+		return colors.GreenColor + "unpacked" + colors.ResetColor
+	case "export", "export-lz4":
+		return colors.GreenColor + "exported" + colors.ResetColor
+	case "raw-extract":
+		return colors.GreenColor + "extracted" + colors.ResetColor
+	case "raw-wrap":
+		return colors.GreenColor + "wrapped" + colors.ResetColor
 	}
 	return colors.GreenColor + "decompressed" + colors.ResetColor
 }
 
-func VerifyDVPLFiles(directoryOrFile string, config *Config) (successCount, failureCount, ignoredCount int, err error) {
-	// Initialize counters
-	successCount = 0
-	failureCount = 0
-	ignoredCount = 0
+// VerifyDVPLFiles verifies the .dvpl files in the directory or file specified in the config.
+func VerifyDVPLFiles(directoryOrFile string, config *Config) (Stats, error) {
+	return VerifyDVPLFilesContext(context.Background(), directoryOrFile, config)
+}
 
-	info, err := os.Stat(directoryOrFile)
-	if err != nil {
-		return 0, 0, 0, err
+// VerifyDVPLFilesContext is like VerifyDVPLFiles but stops once ctx is done, returning the counts
+// gathered so far together with an error wrapping the cause of the stop.
+func VerifyDVPLFilesContext(ctx context.Context, directoryOrFile string, config *Config) (Stats, error) {
+	results, err := VerifyFilesContext(ctx, directoryOrFile, config)
+	return ResultStats(results), err
+}
+
+// InfoDVPLFiles prints the footer metadata of .dvpl files in the directory or file as a table.
+// Only the trailing footer of each file is read, so nothing is decompressed.
+func InfoDVPLFiles(directoryOrFile string, config *Config) (Stats, error) {
+	return InfoDVPLFilesContext(context.Background(), directoryOrFile, config)
+}
+
+// InfoDVPLFilesContext is like InfoDVPLFiles but stops once ctx is done, returning the counts
+// gathered so far together with an error wrapping the cause of the stop.
+func InfoDVPLFilesContext(ctx context.Context, directoryOrFile string, config *Config) (Stats, error) {
+	table := tabwriter.NewWriter(Output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "\nFILE\tORIGINAL\tCOMPRESSED\tRATIO\tCRC32\tTYPE\tFOOTER")
+
+	var stats Stats
+	err := infoDVPLFiles(ctx, directoryOrFile, config, table, &stats)
+	table.Flush()
+
+	return stats, err
+}
+
+func infoDVPLFiles(ctx context.Context, directoryOrFile string, config *Config, table *tabwriter.Writer, stats *Stats) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("processing interrupted: %w", err)
 	}
 
-	// Get the path of the currently running executable
-	executablePath, err := os.Executable()
+	info, err := os.Stat(directoryOrFile)
 	if err != nil {
-		return 0, 0, 0, err
+		return err
 	}
 
 	if info.IsDir() {
 		dirList, err := os.ReadDir(directoryOrFile)
 		if err != nil {
-			return 0, 0, 0, err
+			return err
 		}
 
 		for _, dirItem := range dirList {
-			succ, fail, ignored, err := VerifyDVPLFiles(filepath.Join(directoryOrFile, dirItem.Name()), config)
-			if err != nil {
+			if err := infoDVPLFiles(ctx, filepath.Join(directoryOrFile, dirItem.Name()), config, table, stats); err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
 				if config.Verbose {
-					fmt.Printf("\n%sError%s processing directory %s: %v\n", colors.RedColor, colors.ResetColor, dirItem.Name(), err)
+					fmt.Fprintf(Output, "\n%sError%s processing directory %s: %v\n", colors.RedColor, colors.ResetColor, dirItem.Name(), err)
 				}
 			}
-			successCount += succ
-			failureCount += fail
-			ignoredCount += ignored
 		}
-	} else {
-		// Check if the file is the executable itself
-		if directoryOrFile == executablePath {
-			if config.Verbose {
-				fmt.Printf("\n%sIgnoring%s own executable file %s\n", colors.YellowColor, colors.ResetColor, directoryOrFile)
-			}
-			ignoredCount++
-			return successCount, failureCount, ignoredCount, nil
+
+		return nil
+	}
+
+	// Ignore non-.dvpl files
+	if !config.isCompressed(directoryOrFile) {
+		stats.addIgnored()
+		return nil
+	}
+
+	footer, err := dvpl.ReadFooterFile(directoryOrFile)
+	if err != nil {
+		if config.Verbose {
+			fmt.Fprintf(Output, "\n%sFile%s %s %shas no readable footer due to %v%s\n", colors.RedColor, colors.ResetColor, directoryOrFile, colors.RedColor, err, colors.ResetColor)
 		}
+		stats.addFailure()
+		return nil
+	}
 
-		// Ignore non-.dvpl files during verification
-		if !strings.HasSuffix(directoryOrFile, dvplExtension) {
-			if config.Verbose {
-				fmt.Printf("\n%sIgnoring%s file %s\n", colors.YellowColor, colors.ResetColor, directoryOrFile)
+	ratio := "-"
+	if footer.OriginalSize > 0 {
+		ratio = fmt.Sprintf("%.1f%%", float64(footer.CompressedSize)/float64(footer.OriginalSize)*100)
+	}
+	fmt.Fprintf(table, "%s\t%d\t%d\t%s\t%08x\t%s\tv%d\n", directoryOrFile, footer.OriginalSize, footer.CompressedSize, ratio, footer.CRC32, footer.TypeName(), footer.Version)
+
+	stats.addSuccess()
+	return nil
+}
+
+// CompareDVPLFiles decompresses .dvpl files and byte-compares them with the original files next to them.
+// A path may name either file of a pair; directories are walked and every original that has a .dvpl
+// sibling is compared. Mismatches are always printed together with the first differing offset. The
+// returned Stats count matching pairs as successes and mismatching ones as failures.
+func CompareDVPLFiles(directoryOrFile string, config *Config) (Stats, error) {
+	return CompareDVPLFilesContext(context.Background(), directoryOrFile, config)
+}
+
+// CompareDVPLFilesContext is like CompareDVPLFiles but stops once ctx is done, returning the counts
+// gathered so far together with an error wrapping the cause of the stop.
+func CompareDVPLFilesContext(ctx context.Context, directoryOrFile string, config *Config) (Stats, error) {
+	var stats Stats
+	if err := ctx.Err(); err != nil {
+		return stats, fmt.Errorf("processing interrupted: %w", err)
+	}
+	info, err := os.Stat(directoryOrFile)
+	if err != nil {
+		// An explicitly named .dvpl may exist without its original and vice versa
+		if !config.isCompressed(directoryOrFile) {
+			if _, dvplErr := os.Stat(directoryOrFile + config.compressedExt()); dvplErr == nil {
+				stats.addFailure()
+				return stats, compareFailed(directoryOrFile, err)
 			}
-			ignoredCount++
-			return successCount, failureCount, ignoredCount, nil
 		}
+		return stats, err
+	}
 
-		filePath := directoryOrFile
-		fileData, err := os.ReadFile(filePath)
+	if !info.IsDir() {
+		sourcePath, dvplPath := comparePair(directoryOrFile, config)
+		match, offset, err := compareDVPLFile(sourcePath, dvplPath, config)
 		if err != nil {
+			stats.addFailure()
+			return stats, compareFailed(sourcePath, err)
+		}
+		printComparison(sourcePath, dvplPath, match, offset, config)
+		if match {
+			stats.addSuccess()
+		} else {
+			stats.addFailure()
+		}
+		return stats, nil
+	}
+
+	err = compareDirectory(ctx, directoryOrFile, config, &stats)
+	return stats, err
+}
+
+func compareDirectory(ctx context.Context, directory string, config *Config, stats *Stats) error {
+	dirList, err := os.ReadDir(directory)
+	if err != nil {
+		return err
+	}
+
+	for _, dirItem := range dirList {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("processing interrupted: %w", err)
+		}
+		itemPath := filepath.Join(directory, dirItem.Name())
+
+		if dirItem.IsDir() {
+			if err := compareDirectory(ctx, itemPath, config, stats); err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				if config.Verbose {
+					fmt.Fprintf(Output, "\n%sError%s processing directory %s: %v\n", colors.RedColor, colors.ResetColor, dirItem.Name(), err)
+				}
+			}
+			continue
+		}
+
+		// Pairs are visited through their original, so skip .dvpl files and originals without one
+		if config.isCompressed(itemPath) {
+			continue
+		}
+		if _, err := os.Stat(itemPath + config.compressedExt()); err != nil {
 			if config.Verbose {
-				fmt.Printf("\n%sError%s reading file %s: %v\n", colors.RedColor, colors.ResetColor, directoryOrFile, err)
+				fmt.Fprintf(Output, "\n%sIgnoring%s file without dvpl counterpart %s\n", colors.YellowColor, colors.ResetColor, itemPath)
 			}
-			return 0, 0, 0, err
+			stats.addIgnored()
+			continue
 		}
 
-		_, err = dvpl.DecompressDVPL(fileData)
+		match, offset, err := compareDVPLFile(itemPath, itemPath+config.compressedExt(), config)
 		if err != nil {
 			if config.Verbose {
-				fmt.Printf("\n%sFile%s %s %sfailed to verify due to %v%s\n", colors.RedColor, colors.ResetColor, directoryOrFile, colors.RedColor, err, colors.ResetColor)
+				fmt.Fprintf(Output, "\n%sFile%s %s %sfailed to compare due to %v%s\n", colors.RedColor, colors.ResetColor, itemPath, colors.RedColor, err, colors.ResetColor)
 			}
-			return 0, 1, 0, nil // Return failure count as 1 for this file
+			stats.addFailure()
+			continue
 		}
+		printComparison(itemPath, itemPath+config.compressedExt(), match, offset, config)
+		if match {
+			stats.addSuccess()
+		} else {
+			stats.addFailure()
+		}
+	}
 
-		if config.Verbose {
-			fmt.Printf("\n%sFile%s %s has been successfully %s\n", colors.GreenColor, colors.ResetColor, filePath, getAction(config.Mode))
+	return nil
+}
+
+// comparePair returns the original and .dvpl paths for either file of a pair.
+func comparePair(path string, config *Config) (sourcePath, dvplPath string) {
+	if config.isCompressed(path) {
+		return config.trimCompressedExt(path), path
+	}
+	return path, path + config.compressedExt()
+}
+
+// compareDVPLFile decompresses dvplPath and compares it with sourcePath.
+// When the contents differ the offset of the first differing byte is returned.
+func compareDVPLFile(sourcePath, dvplPath string, config *Config) (match bool, offset int64, err error) {
+	sourceData, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return false, 0, err
+	}
+	dvplData, err := os.ReadFile(dvplPath)
+	if err != nil {
+		return false, 0, err
+	}
+	decodedData, err := decompressDVPL(dvplData, config)
+	if err != nil {
+		return false, 0, err
+	}
+
+	if bytes.Equal(sourceData, decodedData) {
+		return true, 0, nil
+	}
+
+	// Find the first differing byte, a shorter file differs where it ends
+	shortest := len(sourceData)
+	if len(decodedData) < shortest {
+		shortest = len(decodedData)
+	}
+	for offset = 0; offset < int64(shortest); offset++ {
+		if sourceData[offset] != decodedData[offset] {
+			break
 		}
+	}
+	return false, offset, nil
+}
 
-		successCount++
+func printComparison(sourcePath, dvplPath string, match bool, offset int64, config *Config) {
+	if !match && !config.Quiet {
+		fmt.Fprintf(Output, "\n%sFile%s %s %sdiffers from%s %s at offset %d (0x%x)\n", colors.RedColor, colors.ResetColor, dvplPath, colors.RedColor, colors.ResetColor, sourcePath, offset, offset)
+		return
 	}
+	if config.Verbose {
+		fmt.Fprintf(Output, "\n%sFile%s %s matches %s%s%s\n", colors.GreenColor, colors.ResetColor, dvplPath, colors.GreenColor, sourcePath, colors.ResetColor)
+	}
+}
 
-	return successCount, failureCount, ignoredCount, nil
+func compareFailed(path string, err error) error {
+	return fmt.Errorf("comparing %s: %w", path, err)
 }
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// lockSuffix is appended to the path of a file to name the sentinel that marks it as being converted.
+const lockSuffix = ".dvpl_lz4.lock"
+
+// errLocked is returned by lockFile when another run holds the lock of the file.
+var errLocked = errors.New("file locked by another run")
+
+// lockFile takes the advisory lock of path by creating its sentinel file exclusively, so two runs on
+// the same directory, e.g. a watcher and a manual run, never convert the same file at once. The
+// sentinel records the process ID and is removed by the returned unlock function. A run killed while
+// converting leaves its sentinel behind, which must then be deleted by hand or bypassed with -no-lock.
+func lockFile(path string) (unlock func(), err error) {
+	lockPath, _ := longPath(path + lockSuffix)
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, errLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(lock, "%d\n", os.Getpid())
+	if closeErr := lock.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(lockPath)
+		return nil, err
+	}
+	return func() { os.Remove(lockPath) }, nil
+}
+
+// isLockFile reports whether path is the sentinel of a lock taken by lockFile.
+func isLockFile(path string) bool {
+	return strings.HasSuffix(path, lockSuffix)
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// maxShortPath is the longest path, in bytes, that Windows handles without the extended-length
+// prefix. It is MAX_PATH minus room for the 8.3 file name CreateDirectory reserves.
+const maxShortPath = 247
+
+// longPath returns the path to open the file with. On Windows, paths too long for MAX_PATH are made
+// absolute and given the \\?\ extended-length prefix, and reported with true so it can be logged.
+// Go already does this for long absolute paths, but not for relative ones or ones with forward
+// slashes, which deep mod folders run into. Elsewhere the path is returned unchanged.
+func longPath(path string) (string, bool) {
+	if runtime.GOOS != "windows" || len(path) <= maxShortPath {
+		return path, false
+	}
+
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return path, false
+	}
+	extended := extendedLengthPath(absolutePath)
+	return extended, extended != absolutePath
+}
+
+// extendedLengthPath adds the \\?\ prefix to a clean absolute Windows path longer than maxShortPath,
+// using \\?\UNC\ for network shares. The prefix turns off path normalization, so separators must
+// already be backslashes.
+func extendedLengthPath(path string) string {
+	if len(path) <= maxShortPath || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	if strings.HasPrefix(path, `\\`) {
+		return `\\?\UNC\` + path[2:]
+	}
+	return `\\?\` + path
+}
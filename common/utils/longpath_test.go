@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtendedLengthPath(t *testing.T) {
+	deep := strings.Repeat(`\mods`, 50)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`C:\Games\WoTB\Data\a.yaml`, `C:\Games\WoTB\Data\a.yaml`},
+		{`C:\Games` + deep, `\\?\C:\Games` + deep},
+		{`\\server\share` + deep, `\\?\UNC\server\share` + deep},
+		{`\\?\C:\Games` + deep, `\\?\C:\Games` + deep},
+	}
+
+	for _, test := range tests {
+		if got := extendedLengthPath(test.path); got != test.want {
+			t.Errorf("extendedLengthPath(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
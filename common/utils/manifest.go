@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rifsxd/dvpl_lz4/common/colors"
+)
+
+// WriteManifest writes one "<sha256>  <path>" line for every file produced by a run, in the format
+// understood by sha256sum -c. The hashes are computed from the files on disk, so the manifest
+// reflects what was actually written.
+func WriteManifest(manifestPath string, results []Result) error {
+	var outputs []string
+	for _, result := range results {
+		if result.Failed() || result.Ignored() || result.OutputPath == "" {
+			continue
+		}
+		outputs = append(outputs, result.OutputPath)
+	}
+	sort.Strings(outputs)
+
+	var manifest strings.Builder
+	for _, output := range outputs {
+		sum, err := hashFile(output)
+		if err != nil {
+			return fmt.Errorf("hashing %s: %w", output, err)
+		}
+		fmt.Fprintf(&manifest, "%s  %s\n", sum, output)
+	}
+
+	return os.WriteFile(manifestPath, []byte(manifest.String()), 0644)
+}
+
+// ChecksumManifest re-hashes every file listed in a manifest written by WriteManifest and reports
+// the ones whose content changed or that can no longer be read.
+func ChecksumManifest(manifestPath string, config *Config) (matchCount, mismatchCount int, err error) {
+	return ChecksumManifestContext(context.Background(), manifestPath, config)
+}
+
+// ChecksumManifestContext is like ChecksumManifest but stops once ctx is done, returning the counts
+// gathered so far together with an error wrapping the cause of the stop.
+func ChecksumManifestContext(ctx context.Context, manifestPath string, config *Config) (matchCount, mismatchCount int, err error) {
+	manifestFile, err := os.Open(manifestPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer manifestFile.Close()
+
+	scanner := bufio.NewScanner(manifestFile)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if err := ctx.Err(); err != nil {
+			return matchCount, mismatchCount, fmt.Errorf("processing interrupted: %w", err)
+		}
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		expected, path, found := strings.Cut(line, "  ")
+		if !found {
+			return matchCount, mismatchCount, fmt.Errorf("malformed manifest line %d: %q", lineNumber, line)
+		}
+
+		sum, err := hashFile(path)
+		if err != nil {
+			if !config.Quiet {
+				fmt.Fprintf(Output, "\n%sFile%s %s %scould not be checked due to %v%s\n", colors.RedColor, colors.ResetColor, path, colors.RedColor, err, colors.ResetColor)
+			}
+			mismatchCount++
+			continue
+		}
+
+		if sum != expected {
+			if !config.Quiet {
+				fmt.Fprintf(Output, "\n%sFile%s %s %shas changed%s, expected SHA-256 %s but found %s\n", colors.RedColor, colors.ResetColor, path, colors.RedColor, colors.ResetColor, expected, sum)
+			}
+			mismatchCount++
+			continue
+		}
+
+		if config.Verbose {
+			fmt.Fprintf(Output, "\n%sFile%s %s matches its %smanifest hash%s\n", colors.GreenColor, colors.ResetColor, path, colors.GreenColor, colors.ResetColor)
+		}
+		matchCount++
+	}
+
+	return matchCount, mismatchCount, scanner.Err()
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+// PackDVPLFiles compresses every file below directory that compress mode would convert, honoring the
+// filters of the config, and bundles them into a single pack at packPath. The pack is written to a
+// temporary file first and only renamed into place once complete. One Result is returned per file
+// found, and config.OnResult is called for each of them.
+func PackDVPLFiles(ctx context.Context, directory, packPath string, config *Config) ([]Result, error) {
+	if packPath == "" {
+		return nil, errors.New("pack mode requires -output")
+	}
+
+	// Only look at the files compress mode would pick up, in directory order
+	listConfig := *config
+	listConfig.Mode = "compress"
+	listConfig.Threads = 1
+	listConfig.OnResult = nil
+	listed, err := ListFilesContext(ctx, directory, &listConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// A dry run builds the pack without keeping it, so the reported sizes are still real
+	var packWriter io.Writer = io.Discard
+	var tempFile *os.File
+	if !config.DryRun {
+		tempFile, err = os.CreateTemp(filepath.Dir(packPath), filepath.Base(packPath)+".*.tmp")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tempFile.Name())
+		defer tempFile.Close()
+		packWriter = tempFile
+	}
+
+	report := func(result Result) {
+		if config.OnResult != nil {
+			config.OnResult(result)
+		}
+	}
+
+	root := directory
+	if info, err := os.Stat(directory); err == nil && !info.IsDir() {
+		root = filepath.Dir(directory)
+	}
+
+	// A pack left inside the directory by an earlier run must not be packed into the new one
+	absPackPath, _ := filepath.Abs(packPath)
+
+	pack := dvpl.NewPackWriter(packWriter)
+	results := make([]Result, 0, len(listed))
+	for _, result := range listed {
+		if err := ctx.Err(); err != nil {
+			return results, fmt.Errorf("packing interrupted: %w", err)
+		}
+
+		// Listing also reports .dvpl files as eligible for decompression, they are not packed again
+		if result.Action == "decompress" {
+			result.Action = "ignore"
+			result.Reason = "file already in dvpl format"
+		}
+		if absPath, _ := filepath.Abs(result.Path); absPath == absPackPath && !result.Failed() {
+			result.Action = "ignore"
+			result.Reason = "pack output file"
+		}
+
+		if result.Ignored() || result.Failed() {
+			results = append(results, result)
+			report(result)
+			continue
+		}
+
+		result.Action = "pack"
+		result.OutputPath = packPath
+		result.Err = packFile(pack, root, result.Path, config, &result)
+		results = append(results, result)
+		report(result)
+	}
+
+	if err := pack.Close(); err != nil {
+		return results, err
+	}
+	if tempFile == nil {
+		return results, nil
+	}
+	if err := tempFile.Close(); err != nil {
+		return results, err
+	}
+	return results, os.Rename(tempFile.Name(), packPath)
+}
+
+// packFile compresses the file at path and adds it to the pack under its slash-separated path below root.
+func packFile(pack *dvpl.PackWriter, root, path string, config *Config, result *Result) error {
+	name, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	result.InputSize = int64(len(fileData))
+
+	var dvplData []byte
+	if config.Store {
+		dvplData, err = dvpl.CompressDVPLStored(fileData)
+	} else if config.Acceleration > 1 {
+		dvplData, err = dvpl.CompressDVPLAcceleration(fileData, config.Acceleration)
+	} else {
+		dvplData, err = dvpl.CompressDVPLLevel(fileData, config.Level)
+	}
+	if err == nil && config.Footer == "v2" {
+		dvplData, err = dvpl.ToFooterV2(dvplData, fileData)
+	}
+	if err != nil {
+		return err
+	}
+	result.OutputSize = int64(len(dvplData))
+
+	return pack.Add(filepath.ToSlash(name), dvplData)
+}
+
+// UnpackDVPLFiles restores every entry of the pack at packPath below outputDir, which defaults to the
+// directory holding the pack. Each entry is checked against its footer CRC32 while being decompressed.
+// Entry names that would escape outputDir are rejected. One Result is returned per entry.
+func UnpackDVPLFiles(ctx context.Context, packPath, outputDir string, config *Config) ([]Result, error) {
+	if outputDir == "" {
+		outputDir = filepath.Dir(packPath)
+	}
+
+	packFile, err := os.Open(packPath)
+	if err != nil {
+		return nil, err
+	}
+	defer packFile.Close()
+
+	info, err := packFile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := dvpl.ReadPackIndex(packFile, info.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]Result, 0, len(entries))
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return results, fmt.Errorf("unpacking interrupted: %w", err)
+		}
+
+		result := unpackEntry(packFile, entry, outputDir, config)
+		results = append(results, result)
+		if config.OnResult != nil {
+			config.OnResult(result)
+		}
+	}
+	return results, nil
+}
+
+// unpackEntry decompresses a single pack entry into outputDir.
+func unpackEntry(packFile *os.File, entry dvpl.PackEntry, outputDir string, config *Config) Result {
+	result := Result{Path: entry.Name, Action: "unpack"}
+
+	name := filepath.FromSlash(entry.Name)
+	if !filepath.IsLocal(name) {
+		result.Err = fmt.Errorf("%w: entry name %q escapes the output directory", dvpl.ErrInvalidPack, entry.Name)
+		return result
+	}
+	result.OutputPath = filepath.Join(outputDir, name)
+
+	if existingOutput(&result, config) {
+		return result
+	}
+
+	dvplData, err := dvpl.ReadPackEntry(packFile, entry)
+	if err != nil {
+		result.Err = fmt.Errorf("reading entry: %w", err)
+		return result
+	}
+	result.InputSize = int64(len(dvplData))
+
+	fileData, err := decompressDVPL(dvplData, config)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.OutputSize = int64(len(fileData))
+
+	if config.DryRun {
+		return result
+	}
+
+	if err := os.MkdirAll(filepath.Dir(result.OutputPath), 0755); err != nil {
+		result.Err = fmt.Errorf("preparing output: %w", err)
+		return result
+	}
+	if err := writeFileAtomic(result.OutputPath, fileData, time.Time{}); err != nil {
+		result.Err = fmt.Errorf("writing file %s: %w", result.OutputPath, err)
+	}
+	return result
+}
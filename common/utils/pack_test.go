@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPackUnpackTree(t *testing.T) {
+	source := t.TempDir()
+	files := map[string][]byte{
+		"a.yaml":             bytes.Repeat([]byte("key: value\n"), 100),
+		"maps/b.sc2":         []byte("binary \x00\x01\x02 map data"),
+		"maps/deep/c.txt":    []byte("nested"),
+		"already.yaml.dvpl":  []byte("skipped by compress mode"),
+		"maps/deep/empty.sc": {},
+	}
+	for name, data := range files {
+		path := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	packPath := filepath.Join(t.TempDir(), "data.dvplpack")
+	config := &Config{Threads: 1, Overwrite: true}
+	results, err := PackDVPLFiles(context.Background(), source, packPath, config)
+	if err != nil {
+		t.Fatalf("PackDVPLFiles: %v", err)
+	}
+	if packed, failed, ignored := CountResults(results); packed != 4 || failed != 0 || ignored != 1 {
+		t.Fatalf("packed %d, failed %d, ignored %d, want 4, 0, 1", packed, failed, ignored)
+	}
+
+	restored := t.TempDir()
+	results, err = UnpackDVPLFiles(context.Background(), packPath, restored, config)
+	if err != nil {
+		t.Fatalf("UnpackDVPLFiles: %v", err)
+	}
+	if unpacked, failed, _ := CountResults(results); unpacked != 4 || failed != 0 {
+		t.Fatalf("unpacked %d, failed %d, want 4, 0", unpacked, failed)
+	}
+
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(restored, filepath.FromSlash(name)))
+		if name == "already.yaml.dvpl" {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s should not have been packed", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("reading restored %s: %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("restored %s does not match the original", name)
+		}
+	}
+}
@@ -0,0 +1,1293 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+// ErrOutputExists is reported for files whose output already exists when overwriting is disabled.
+var ErrOutputExists = errors.New("output file already exists")
+
+// ErrFileTimeout is reported for files that took longer than -file-timeout to read and convert.
+var ErrFileTimeout = errors.New("file timed out")
+
+// Result describes what happened to a single file during processing.
+type Result struct {
+	Path       string        // Source file path
+	OutputPath string        // Converted file path, empty when nothing was produced
+	Action     string        // "compress", "decompress", "repair", "pack", "unpack", "export", "export-lz4", "raw-extract", "raw-wrap", "walk" or "ignore"
+	Reason     string        // Why the file was ignored
+	InputSize  int64         // Size of the source data in bytes
+	OutputSize int64         // Size of the converted data in bytes
+	Elapsed    time.Duration // Time spent compressing or decompressing the data
+	ReadTime   time.Duration // Time spent reading the source file
+	WriteTime  time.Duration // Time spent writing the converted file
+	Err        error         // Set when the file failed to convert
+	RemoveErr  error         // Set when the original could not be deleted after a successful conversion
+	LongPath   bool          // The source or output path needed the Windows long path prefix
+	Stored     bool          // The compressed file holds the data uncompressed (type 0) instead of LZ4
+	Warn       bool          // The file was ignored for a reason worth showing without -verbose
+	Nesting    int           // DVPL layers found nested inside the file by -deep verification
+	Removed    bool          // The original was deleted after a successful conversion
+	CRC32      uint32        // CRC32 from the footer of the compressed side of a conversion
+	Finished   time.Time     // When processing of the file ended
+}
+
+// Ignored reports whether the file was skipped.
+func (result Result) Ignored() bool {
+	return result.Action == "ignore"
+}
+
+// Failed reports whether processing the file failed.
+func (result Result) Failed() bool {
+	return result.Err != nil
+}
+
+// CountResults tallies successful, failed and ignored files.
+func CountResults(results []Result) (successCount, failureCount, ignoredCount int) {
+	for _, result := range results {
+		switch {
+		case result.Failed():
+			failureCount++
+		case result.Ignored():
+			ignoredCount++
+		default:
+			successCount++
+		}
+	}
+	return successCount, failureCount, ignoredCount
+}
+
+// Timings sums the bytes moved and the time spent in each stage of a run, to tell I/O-bound runs from CPU-bound ones.
+// Durations are added up across workers, so with several threads they can exceed the wall-clock time.
+type Timings struct {
+	BytesRead    int64
+	BytesWritten int64
+	ReadTime     time.Duration
+	ConvertTime  time.Duration
+	WriteTime    time.Duration
+}
+
+// SumTimings adds up the sizes and stage durations of the files that were converted successfully.
+func SumTimings(results []Result) Timings {
+	var timings Timings
+	for _, result := range results {
+		if result.Failed() || result.Ignored() {
+			continue
+		}
+		timings.BytesRead += result.InputSize
+		timings.BytesWritten += result.OutputSize
+		timings.ReadTime += result.ReadTime
+		timings.ConvertTime += result.Elapsed
+		timings.WriteTime += result.WriteTime
+	}
+	return timings
+}
+
+// ExtensionStats sums up the successfully converted files of one extension.
+type ExtensionStats struct {
+	Extension  string // Extension of the uncompressed file name, e.g. ".yaml", or "" for files without one.
+	Files      int
+	InputSize  int64
+	OutputSize int64
+}
+
+// Saved is the number of bytes the conversion saved, negative when the files grew.
+func (stats ExtensionStats) Saved() int64 {
+	return stats.InputSize - stats.OutputSize
+}
+
+// SumByExtension groups the successfully converted files by the extension of their uncompressed name, so
+// compressing and decompressing the same tree give the same keys. The groups are sorted by bytes saved,
+// largest first.
+func SumByExtension(results []Result, config *Config) []ExtensionStats {
+	byExtension := make(map[string]*ExtensionStats)
+	for _, result := range results {
+		if result.Failed() || result.Ignored() {
+			continue
+		}
+		extension := strings.ToLower(filepath.Ext(config.trimCompressedExt(result.Path)))
+		stats, ok := byExtension[extension]
+		if !ok {
+			stats = &ExtensionStats{Extension: extension}
+			byExtension[extension] = stats
+		}
+		stats.Files++
+		stats.InputSize += result.InputSize
+		stats.OutputSize += result.OutputSize
+	}
+
+	sorted := make([]ExtensionStats, 0, len(byExtension))
+	for _, stats := range byExtension {
+		sorted = append(sorted, *stats)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Saved() != sorted[j].Saved() {
+			return sorted[i].Saved() > sorted[j].Saved()
+		}
+		return sorted[i].Extension < sorted[j].Extension
+	})
+	return sorted
+}
+
+// ProcessFiles process files in the directory or file specified in the config and returns one Result per file.
+// Files inside a directory are converted concurrently by up to config.Threads workers, so the order of the
+// results is not deterministic unless config.Threads is 1. Nothing is printed; config.OnResult can be set to
+// observe results as they are produced.
+func ProcessFiles(directoryOrFile string, config *Config) ([]Result, error) {
+	return ProcessFilesContext(context.Background(), directoryOrFile, config)
+}
+
+// ProcessFilesContext is like ProcessFiles but stops once ctx is done, or after the first failed file when
+// config.FailFast is set. Files already being converted are finished so no output is left half-written,
+// no new file is read, and the results gathered so far are returned together with an error wrapping the
+// cause of the stop. With config.PruneEmpty, directories left empty by deleted originals are removed.
+func ProcessFilesContext(ctx context.Context, directoryOrFile string, config *Config) ([]Result, error) {
+	results, err := walkRun(ctx, directoryOrFile, config, (*processRun).processFile, true)
+	if config.PruneEmpty && !config.DryRun {
+		if _, pruneErr := pruneEmptyDirs(directoryOrFile, results); pruneErr != nil && err == nil {
+			err = pruneErr
+		}
+	}
+	return results, err
+}
+
+// ListFilesContext walks directoryOrFile like ProcessFilesContext and reports which files would be
+// converted, without reading them. Eligible files get the "compress" or "decompress" action that
+// matches their extension and their size as InputSize, all others are ignored with a reason.
+func ListFilesContext(ctx context.Context, directoryOrFile string, config *Config) ([]Result, error) {
+	return walkFiles(ctx, directoryOrFile, config, (*processRun).listFile)
+}
+
+// walkFiles runs handle on directoryOrFile, or on every file below it, and collects the results.
+func walkFiles(ctx context.Context, directoryOrFile string, config *Config, handle func(*processRun, string, os.FileInfo) Result) ([]Result, error) {
+	return walkRun(ctx, directoryOrFile, config, handle, false)
+}
+
+// walkRun is walkFiles for conversions when staged is set, whose files then go through the stages of a
+// pipeline if -io-threads or -cpu-threads asks for it.
+func walkRun(ctx context.Context, directoryOrFile string, config *Config, handle func(*processRun, string, os.FileInfo) Result, staged bool) ([]Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("processing interrupted: %w", err)
+	}
+
+	// ".", "./" and "dir/" name the same tree as its absolute path, so they are walked and compared alike
+	directoryOrFile, err := filepath.Abs(directoryOrFile)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(directoryOrFile)
+	if err != nil {
+		return nil, err
+	}
+
+	root := directoryOrFile
+	if !info.IsDir() {
+		root = filepath.Dir(directoryOrFile)
+	}
+	run, err := newProcessRun(ctx, config, root, handle)
+	if err != nil {
+		return nil, err
+	}
+	defer run.cancel(nil)
+
+	if !info.IsDir() {
+		run.report(handle(run, directoryOrFile, info))
+		return run.results, nil
+	}
+
+	pool := run.newPool(staged)
+	err = run.processDirectory(directoryOrFile, 1, pool)
+	run.queueHeld(pool)
+	pool.wait()
+	run.stages.wait()
+
+	if run.ctx.Err() != nil {
+		err = fmt.Errorf("processing interrupted: %w", context.Cause(run.ctx))
+	}
+	return run.results, err
+}
+
+// newProcessRun prepares a run whose outputs mirror root. The caller must call run.cancel once done.
+func newProcessRun(ctx context.Context, config *Config, root string, handle func(*processRun, string, os.FileInfo) Result) (*processRun, error) {
+	// Get the path of the currently running executable
+	executablePath, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(executablePath); err == nil {
+		executablePath = resolved
+	}
+
+	// With fail-fast the first failed file cancels the rest of the run
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	run := &processRun{
+		ctx:            ctx,
+		cancel:         cancel,
+		config:         config,
+		root:           root,
+		executablePath: executablePath,
+		handle:         handle,
+	}
+	run.scratch.New = func() interface{} { return new([]byte) }
+	run.ioLimit = newStageLimit(config.IOThreads)
+	run.cpuLimit = newStageLimit(config.CPUThreads)
+	return run, nil
+}
+
+// ProcessPathsContext processes several files or directories in turn and returns their combined results.
+// A path that cannot be processed, for example because it does not exist, is reported as a failed Result
+// and the remaining paths are still processed, unless ctx is done or config.FailFast stops the run.
+func ProcessPathsContext(ctx context.Context, paths []string, config *Config) ([]Result, error) {
+	var results []Result
+	for _, path := range paths {
+		pathResults, err := ProcessFilesContext(ctx, path, config)
+		results = append(results, pathResults...)
+		if err == nil {
+			continue
+		}
+
+		if ctx.Err() != nil || config.FailFast {
+			return results, err
+		}
+		result := Result{Path: path, Action: config.Mode, Err: err}
+		results = append(results, result)
+		if config.OnResult != nil {
+			config.OnResult(result)
+		}
+	}
+	return results, nil
+}
+
+// processRun holds the state shared by every file of a single ProcessFiles call.
+type processRun struct {
+	ctx            context.Context
+	cancel         context.CancelCauseFunc
+	config         *Config
+	root           string // Input directory that output paths are mirrored from
+	executablePath string
+	handle         func(*processRun, string, os.FileInfo) Result // Called for every file found
+
+	mu      sync.Mutex
+	results []Result
+
+	scratch sync.Pool // *[]byte output buffers reused by compression
+
+	ioLimit  stageLimit // Bounds concurrent reads and writes with -io-threads
+	cpuLimit stageLimit // Bounds concurrent conversions with -cpu-threads
+	stages   *pipeline  // Convert and write stages of a staged conversion, nil when files are handled whole
+
+	held []heldFile // Files found so far, kept back until the walk ends when config.Order sorts them
+}
+
+// heldFile is a file found by the walk that waits to be queued in config.Order.
+type heldFile struct {
+	path string
+	info os.FileInfo
+}
+
+// fileOrders are the orders accepted by -order, mapping each to whether file a is processed before file b.
+var fileOrders = map[string]func(a, b heldFile) bool{
+	"size-desc": func(a, b heldFile) bool { return a.info.Size() > b.info.Size() },
+	"size-asc":  func(a, b heldFile) bool { return a.info.Size() < b.info.Size() },
+	"name":      func(a, b heldFile) bool { return a.path < b.path },
+}
+
+// report records a result and hands it to config.OnResult, one call at a time.
+// With config.FailFast a failed result stops the run.
+func (run *processRun) report(result Result) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	if result.Finished.IsZero() {
+		result.Finished = time.Now()
+	}
+	run.results = append(run.results, result)
+	if run.config.Progress != nil {
+		run.config.Progress.Record(result)
+	}
+	if run.config.OnResult != nil {
+		run.config.OnResult(result)
+	}
+
+	if result.Failed() && run.config.FailFast {
+		run.cancel(fmt.Errorf("stopped after %s failed: %w", result.Path, result.Err))
+	}
+}
+
+// excludedDir reports whether the base name of directory matches one of the comma-separated
+// -exclude-dir names or glob patterns.
+func excludedDir(directory, excludeDir string) bool {
+	if excludeDir == "" {
+		return false
+	}
+	return matchesInclude(directory, excludeDir)
+}
+
+// matchesInclude reports whether the base name of path matches one of the comma-separated include patterns.
+// An empty include list matches every file.
+func matchesInclude(path, include string) bool {
+	if include == "" {
+		return true
+	}
+
+	// Extensions differ only in case on Windows, so patterns match regardless of it
+	baseName := strings.ToLower(filepath.Base(path))
+	for _, pattern := range strings.Split(include, ",") {
+		if matched, _ := filepath.Match(strings.ToLower(strings.TrimSpace(pattern)), baseName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// outputPath maps a converted file name below config.Output, mirroring its location under the input root.
+func (run *processRun) outputPath(name string) (string, error) {
+	if run.config.Output == "" {
+		return name, nil
+	}
+
+	rel, err := filepath.Rel(run.root, name)
+	if err != nil {
+		return "", err
+	}
+	// Listed files can live anywhere, never let them escape the output directory
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s is outside %s", name, run.root)
+	}
+
+	return filepath.Join(run.config.Output, rel), nil
+}
+
+// outputTemplateToken matches the {token} placeholders of -output-template.
+var outputTemplateToken = regexp.MustCompile(`\{[^{}]*\}`)
+
+// validateOutputTemplate checks that template only uses the tokens expandOutputTemplate knows,
+// and names every file differently through {name} or {stem}.
+func validateOutputTemplate(template string) error {
+	for _, token := range outputTemplateToken.FindAllString(template, -1) {
+		switch token {
+		case "{dir}", "{name}", "{stem}", "{ext}":
+		default:
+			return fmt.Errorf("unknown token %s, expected {dir}, {name}, {stem} or {ext}", token)
+		}
+	}
+	if !strings.Contains(template, "{name}") && !strings.Contains(template, "{stem}") {
+		return errors.New("it must contain {name} or {stem}, or every file would be written to the same path")
+	}
+	return nil
+}
+
+// expandOutputTemplate builds the output path of a file from template, given the path it would be
+// written to without one. {dir} is the directory of the file, {name} the default output file name,
+// {ext} its extension and {stem} the name without {ext}. Paths are relative to the working directory.
+func expandOutputTemplate(template, defaultPath string) string {
+	name := filepath.Base(defaultPath)
+	ext := filepath.Ext(name)
+	replacer := strings.NewReplacer(
+		"{dir}", filepath.Dir(defaultPath),
+		"{name}", name,
+		"{stem}", strings.TrimSuffix(name, ext),
+		"{ext}", ext,
+	)
+	return filepath.Clean(filepath.FromSlash(replacer.Replace(template)))
+}
+
+// separateOutput reports whether converted files are written outside the input tree,
+// in which case originals are never deleted.
+func (run *processRun) separateOutput() bool {
+	if run.config.Output == "" {
+		return false
+	}
+
+	outputDir, err := filepath.Abs(run.config.Output)
+	if err != nil {
+		return true
+	}
+	inputDir, err := filepath.Abs(run.root)
+	if err != nil {
+		return true
+	}
+	return outputDir != inputDir
+}
+
+// stageLimit bounds how many files are in one stage of a conversion at once, so the I/O and CPU
+// stages can be tuned independently. A nil limit lets every worker through.
+type stageLimit chan struct{}
+
+func newStageLimit(threads int) stageLimit {
+	if threads < 1 {
+		return nil
+	}
+	return make(stageLimit, threads)
+}
+
+// acquire blocks until the stage has room for another file.
+func (limit stageLimit) acquire() {
+	if limit != nil {
+		limit <- struct{}{}
+	}
+}
+
+// release hands the slot taken by acquire to the next file.
+func (limit stageLimit) release() {
+	if limit != nil {
+		<-limit
+	}
+}
+
+// workerPool runs file conversions on a bounded number of goroutines.
+type workerPool struct {
+	wg  sync.WaitGroup
+	sem chan struct{}
+}
+
+func newWorkerPool(threads int) *workerPool {
+	if threads < 1 {
+		threads = runtime.NumCPU()
+	}
+	return &workerPool{sem: make(chan struct{}, threads)}
+}
+
+// submit schedules task on the pool, blocking while every worker is busy.
+// With a single worker tasks therefore run one after another in submission order.
+func (p *workerPool) submit(task func()) {
+	p.sem <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.sem
+			p.wg.Done()
+		}()
+		task()
+	}()
+}
+
+// wait blocks until every submitted task has finished.
+func (p *workerPool) wait() {
+	p.wg.Wait()
+}
+
+// newPool returns the pool the files of the run are submitted to. When staged is set and -io-threads or
+// -cpu-threads is, the pool only reads files, and the convert and write stages are started next to it.
+func (run *processRun) newPool(staged bool) *workerPool {
+	if !staged || (run.config.IOThreads < 1 && run.config.CPUThreads < 1) {
+		return newWorkerPool(run.config.workerCount())
+	}
+
+	ioThreads := run.config.stageThreads(run.config.IOThreads)
+	run.stages = &pipeline{convert: make(chan *conversion), write: make(chan *conversion)}
+	for i := 0; i < run.config.stageThreads(run.config.CPUThreads); i++ {
+		run.stages.converting.Add(1)
+		go run.convertStage()
+	}
+	for i := 0; i < ioThreads; i++ {
+		run.stages.writing.Add(1)
+		go run.writeStage()
+	}
+	return newWorkerPool(ioThreads)
+}
+
+// pipeline moves the files of a conversion through separate read, convert and write stages, so slow disks
+// and slow conversions are tuned apart with -io-threads and -cpu-threads. The pool of the run reads files,
+// a channel hands each one to the goroutines converting files, and another to those writing them. Reads
+// and writes share the -io-threads limit, so no more files touch the disk at once than it allows.
+type pipeline struct {
+	convert    chan *conversion // Files read and waiting to be converted
+	write      chan *conversion // Files converted and waiting to be written
+	converting sync.WaitGroup
+	writing    sync.WaitGroup
+}
+
+// wait blocks until every file handed to the pipeline is written. It must be called once the pool feeding it
+// is done, and does nothing on a nil pipeline.
+func (p *pipeline) wait() {
+	if p == nil {
+		return
+	}
+	close(p.convert)
+	p.converting.Wait()
+	close(p.write)
+	p.writing.Wait()
+}
+
+// readStage reads a file on the pool and hands it to the convert stage, or reports it if it is not converted.
+func (run *processRun) readStage(path string, info os.FileInfo) {
+	file, ok := run.readFile(path, info)
+	if !ok {
+		run.report(run.finishFile(file))
+		return
+	}
+	run.stages.convert <- file
+}
+
+// convertStage converts files read by readStage and hands them to the write stage. Files already read when
+// the run is stopped are still converted and written, so none is left behind half done.
+func (run *processRun) convertStage() {
+	defer run.stages.converting.Done()
+	for file := range run.stages.convert {
+		if !run.convertFile(file) {
+			run.report(run.finishFile(file))
+			continue
+		}
+		run.stages.write <- file
+	}
+}
+
+// writeStage writes files converted by convertStage and reports them.
+func (run *processRun) writeStage() {
+	defer run.stages.writing.Done()
+	for file := range run.stages.write {
+		run.writeFile(file)
+		run.report(run.finishFile(file))
+	}
+}
+
+// processDirectory walks a directory and submits every file it contains to the pool.
+// Entries of the directory sit at the given depth, where 1 is the input root.
+// Errors below the top-level directory are reported as failed results instead of stopping the walk.
+func (run *processRun) processDirectory(directory string, depth int, pool *workerPool) error {
+	if err := run.ctx.Err(); err != nil {
+		return err
+	}
+
+	dirList, err := os.ReadDir(directory)
+	if err != nil {
+		return fmt.Errorf("reading directory: %w", err)
+	}
+
+	for _, dirItem := range dirList {
+		itemPath := filepath.Join(directory, dirItem.Name())
+
+		// Dotfiles and dot-directories such as .git are skipped whole, without reading anything below them
+		if run.config.SkipHidden && strings.HasPrefix(dirItem.Name(), ".") {
+			run.report(Result{Path: itemPath, Action: "ignore", Reason: "hidden file or directory"})
+			continue
+		}
+
+		info, err := os.Lstat(itemPath)
+		if err == nil && info.Mode()&os.ModeSymlink != 0 {
+			// Symlinks can point back at an ancestor or outside the tree, skip them unless asked to follow
+			if !run.config.FollowSymlinks {
+				run.report(Result{Path: itemPath, Action: "ignore", Reason: "symbolic link"})
+				continue
+			}
+			info, err = os.Stat(itemPath)
+		}
+
+		if err == nil && info.IsDir() && excludedDir(itemPath, run.config.ExcludeDir) {
+			// Excluded trees are skipped whole, without reading anything below them
+			run.report(Result{Path: itemPath, Action: "ignore", Reason: "excluded directory"})
+			continue
+		}
+
+		if err == nil && info.IsDir() && run.config.MaxDepth > 0 && depth >= run.config.MaxDepth {
+			err = run.ignoreTree(itemPath)
+		} else if err == nil && info.IsDir() {
+			err = run.processDirectory(itemPath, depth+1, pool)
+		} else if err == nil {
+			run.queue(pool, itemPath, info)
+			continue
+		}
+
+		if ctxErr := run.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if err != nil {
+			run.report(Result{Path: itemPath, Action: run.config.Mode, Err: err})
+		}
+	}
+
+	return nil
+}
+
+// queue hands a file to the worker pool.
+// With config.Order set the file is held back instead, so queueHeld can submit every file in order.
+func (run *processRun) queue(pool *workerPool, path string, info os.FileInfo) {
+	if _, ok := fileOrders[run.config.Order]; ok {
+		run.held = append(run.held, heldFile{path, info})
+		return
+	}
+	run.submit(pool, path, info)
+}
+
+// submit hands a file to the worker pool right away.
+func (run *processRun) submit(pool *workerPool, path string, info os.FileInfo) {
+	pool.submit(func() {
+		// Files queued before cancellation are dropped without being read
+		if run.ctx.Err() != nil {
+			return
+		}
+		if run.stages != nil {
+			run.readStage(path, info)
+			return
+		}
+		run.report(run.handle(run, path, info))
+	})
+}
+
+// queueHeld sorts the files held back by queue in config.Order and submits them to the pool. It must be
+// called from the walking goroutine once the walk is over, and does nothing without config.Order.
+func (run *processRun) queueHeld(pool *workerPool) {
+	less, ok := fileOrders[run.config.Order]
+	if !ok {
+		return
+	}
+	held := run.held
+	run.held = nil
+	sort.SliceStable(held, func(i, j int) bool { return less(held[i], held[j]) })
+
+	for _, file := range held {
+		run.submit(pool, file.path, file.info)
+	}
+}
+
+// ignoreTree reports every file below a directory past the maximum depth as ignored.
+// It does not follow symlinks, so looped trees cannot make it run away.
+func (run *processRun) ignoreTree(directory string) error {
+	return filepath.WalkDir(directory, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			run.report(Result{Path: path, Action: "ignore", Reason: "file beyond maximum depth"})
+		}
+		return run.ctx.Err()
+	})
+}
+
+// Reasons shouldProcess gives for files of the other kind than the mode converts.
+const (
+	reasonCompressed   = "already compressed file"
+	reasonUncompressed = "file without the compressed extension"
+)
+
+// modeHints point compress and decompress runs given files of the other kind at the mode that converts them.
+var modeHints = map[string]string{
+	reasonCompressed:   " (use -mode decompress?)",
+	reasonUncompressed: " (use -mode compress?)",
+}
+
+// shouldProcess reports whether the file at path is converted in the configured mode. Otherwise the file is
+// ignored and reason says why, or is empty in modes that convert no files.
+func shouldProcess(path string, config *Config, execPath string) (process bool, reason string) {
+	// Check if the file is the executable itself
+	if isExecutable(path, execPath) {
+		return false, "own executable file"
+	}
+	if isLockFile(path) {
+		return false, "lock file of a running conversion"
+	}
+	if config.isSidecar(path) {
+		return false, "checksum sidecar file"
+	}
+
+	isDVPL := config.isCompressed(path)
+	if config.Mode == "compress" && isDVPL {
+		return false, reasonCompressed
+	}
+	if config.Mode == "decompress" && !isDVPL {
+		return false, reasonUncompressed
+	}
+	if config.Mode != "compress" && config.Mode != "decompress" {
+		return false, ""
+	}
+
+	if config.Ignore != "" {
+		ext := filepath.Ext(path)
+		for _, ignored := range strings.Split(config.Ignore, ",") {
+			if strings.EqualFold(ext, strings.TrimSpace(ignored)) {
+				return false, "file with ignored extension"
+			}
+		}
+	}
+
+	if !matchesInclude(path, config.Include) {
+		return false, "file not matching -include"
+	}
+
+	return true, ""
+}
+
+// isExecutable reports whether path names the executable at execPath, which has its symlinks resolved,
+// however path is spelled. Only paths with the same base name are resolved, keeping the check cheap.
+func isExecutable(path, execPath string) bool {
+	if path == execPath {
+		return true
+	}
+	if filepath.Base(path) != filepath.Base(execPath) {
+		return false
+	}
+	resolved, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	if real, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = real
+	}
+	return resolved == execPath
+}
+
+// listFile reports whether a single file would be compressed or decompressed without reading it.
+func (run *processRun) listFile(path string, info os.FileInfo) Result {
+	action := "compress"
+	if run.config.isCompressed(path) {
+		action = "decompress"
+	}
+
+	modeConfig := *run.config
+	modeConfig.Mode = action
+	if process, reason := shouldProcess(path, &modeConfig, run.executablePath); !process {
+		return Result{Path: path, Action: "ignore", Reason: reason}
+	}
+	if !modifiedSince(info, run.config) {
+		return Result{Path: path, Action: "ignore", Reason: "file not modified since -since"}
+	}
+	if tooLarge(info, run.config) {
+		return Result{Path: path, Action: "ignore", Reason: "file larger than -max-file-size"}
+	}
+	return Result{Path: path, Action: action, InputSize: info.Size()}
+}
+
+// autoConfig returns the config that converts path in auto mode, which decompresses files with the compressed
+// extension and compresses any other one. Files whose converted counterpart sits next to them get no config
+// but the result ignoring them, as converting both would swap them and auto mode cannot tell which of the
+// two is current.
+func (run *processRun) autoConfig(path string) (*Config, Result) {
+	modeConfig := *run.config
+	modeConfig.Mode = "compress"
+	counterpart := path + modeConfig.compressedExt()
+	if modeConfig.isCompressed(path) {
+		modeConfig.Mode = "decompress"
+		counterpart = modeConfig.trimCompressedExt(path)
+	}
+
+	if process, _ := shouldProcess(path, &modeConfig, run.executablePath); process {
+		if _, err := os.Lstat(counterpart); err == nil {
+			return nil, Result{Path: path, Action: "ignore", Reason: "file whose compressed or decompressed counterpart also exists", Warn: true}
+		}
+	}
+	return &modeConfig, Result{}
+}
+
+// processFile compresses or decompresses a single file according to the config.
+func (run *processRun) processFile(directoryOrFile string, info os.FileInfo) Result {
+	file, ok := run.readFile(directoryOrFile, info)
+	if ok && run.convertFile(file) {
+		run.writeFile(file)
+	}
+	return run.finishFile(file)
+}
+
+// conversion is a file on its way through the read, convert and write stages of processFile.
+type conversion struct {
+	result    Result
+	info      os.FileInfo
+	config    *Config // The run's config, or the one of the file's mode in auto mode
+	task      *fileTask
+	unlock    func()
+	filePath  string  // Source path, in its extended-length form where needed
+	writeName string  // Output path, in its extended-length form where needed
+	data      []byte  // Contents of the source file
+	block     []byte  // Converted contents
+	scratch   *[]byte // Pooled output buffer of a compression
+}
+
+// readFile checks whether a single file is to be converted and reads it. It reports false when the file
+// is done with before being converted, its result then says why.
+func (run *processRun) readFile(directoryOrFile string, info os.FileInfo) (*conversion, bool) {
+	config := run.config
+	if config.Mode == "auto" {
+		var result Result
+		if config, result = run.autoConfig(directoryOrFile); config == nil {
+			return &conversion{result: result}, false
+		}
+	}
+
+	file := &conversion{result: Result{Path: directoryOrFile, Action: config.Mode}, info: info, config: config}
+	result := &file.result
+
+	if process, reason := shouldProcess(directoryOrFile, config, run.executablePath); !process {
+		result.Action = "ignore"
+		result.Reason = reason + modeHints[reason]
+		return file, false
+	}
+	if !modifiedSince(info, config) {
+		result.Action = "ignore"
+		result.Reason = "file not modified since -since"
+		return file, false
+	}
+	// Files are converted in memory, so huge ones are skipped before anything is read
+	if tooLarge(info, config) {
+		result.Action = "ignore"
+		result.Reason = "file larger than -max-file-size"
+		return file, false
+	}
+
+	// Another run converting the same file would clobber the output, so it is left to that run
+	if !config.NoLock && !config.DryRun {
+		var err error
+		file.unlock, err = lockFile(directoryOrFile)
+		if errors.Is(err, errLocked) {
+			result.Action = "ignore"
+			result.Reason = "file being converted by another run (use -no-lock to convert it anyway)"
+			result.Warn = true
+			return file, false
+		}
+		if err != nil {
+			result.Err = fmt.Errorf("locking file: %w", err)
+			return file, false
+		}
+	}
+
+	// Reading and converting share the -file-timeout budget, writing is never abandoned halfway.
+	// The lock is only released once a read or conversion abandoned on timeout has returned.
+	file.task = run.startFile()
+
+	newName := directoryOrFile + config.compressedExt()
+	if config.Mode == "decompress" {
+		newName = config.trimCompressedExt(directoryOrFile)
+	}
+	if config.OutputTemplate != "" {
+		newName = expandOutputTemplate(config.OutputTemplate, newName)
+	}
+
+	newName, err := run.outputPath(newName)
+	if err != nil {
+		result.Err = fmt.Errorf("preparing output: %w", err)
+		return file, false
+	}
+	result.OutputPath = newName
+	if filepath.Clean(newName) == filepath.Clean(directoryOrFile) {
+		result.Err = errors.New("preparing output: output path is the file itself")
+		return file, false
+	}
+
+	if existingOutput(result, config) {
+		return file, false
+	}
+	if config.Mode == "decompress" && !config.OverwriteNewer && newerOutput(newName, info) {
+		result.Action = "ignore"
+		result.Reason = "file with a newer decompressed output (use -overwrite to replace it)"
+		result.Warn = true
+		return file, false
+	}
+
+	// Paths past MAX_PATH only work on Windows in their extended-length form
+	var longSource, longOutput bool
+	file.filePath, longSource = longPath(directoryOrFile)
+	file.writeName, longOutput = longPath(newName)
+	result.LongPath = longSource || longOutput
+
+	run.ioLimit.acquire()
+	readStart := time.Now()
+	err = file.task.withinDeadline(run.ioLimit.release, func(ctx context.Context) error {
+		return run.retry(func() (err error) {
+			file.data, err = readFileContext(ctx, file.filePath)
+			return err
+		})
+	})
+	result.ReadTime = time.Since(readStart)
+	if err != nil {
+		result.Err = fmt.Errorf("reading file: %w", err)
+		return file, false
+	}
+	result.InputSize = int64(len(file.data))
+
+	// Never wrap a file that is already DVPL data, even if it lacks the extension
+	if config.Mode == "compress" && dvpl.IsDVPL(file.data) {
+		result.Action = "ignore"
+		result.Reason = "file that already contains DVPL data"
+		return file, false
+	}
+	return file, true
+}
+
+// convertFile compresses or decompresses a file read by readFile. It reports false when the conversion
+// failed, or when there is nothing to write in a dry run.
+func (run *processRun) convertFile(file *conversion) bool {
+	config, result := file.config, &file.result
+	isCompression := config.Mode == "compress"
+
+	// Compress into a pooled buffer so workers reuse their output memory from file to file.
+	// finishFile never hands out a buffer still being written by a timed out conversion again.
+	if isCompression {
+		file.scratch = run.scratch.Get().(*[]byte)
+	}
+
+	run.cpuLimit.acquire()
+	convertStart := time.Now()
+	var processedBlock []byte
+	var stored bool
+	err := file.task.withinDeadline(run.cpuLimit.release, func(ctx context.Context) (err error) {
+		var block []byte
+		if isCompression && config.Store {
+			block, err = dvpl.CompressDVPLStoredInto(*file.scratch, file.data)
+		} else if isCompression && config.Acceleration > 1 {
+			block, err = dvpl.CompressDVPLAccelerationInto(*file.scratch, file.data, config.Acceleration)
+		} else if isCompression {
+			block, err = dvpl.CompressDVPLLevelInto(*file.scratch, file.data, config.Level)
+		} else {
+			block, err = decompressDVPL(file.data, config)
+		}
+		// Blocks that LZ4 barely shrinks are not worth decompressing in the game, store those instead
+		if isCompression && err == nil && !config.Store && config.MinRatio > 0 && len(file.data) > 0 &&
+			float64(len(block)-dvpl.FooterSize)/float64(len(file.data)) > config.MinRatio {
+			block, err = dvpl.CompressDVPLStoredInto(block, file.data)
+		}
+		if isCompression && err == nil {
+			stored = isStored(block)
+		}
+		if isCompression && err == nil && config.Footer == "v2" {
+			block, err = dvpl.ToFooterV2(block, file.data)
+		}
+		processedBlock = block
+		return err
+	})
+	result.Elapsed = time.Since(convertStart)
+	if err != nil {
+		result.Err = err
+		return false
+	}
+	file.block = processedBlock
+	result.Stored = stored
+	if file.scratch != nil {
+		*file.scratch = processedBlock
+	}
+	result.OutputSize = int64(len(processedBlock))
+
+	// Keep the footer CRC32 of the DVPL side for the -csv conversion log
+	dvplData := file.data
+	if isCompression {
+		dvplData = processedBlock
+	}
+	if footer, err := dvpl.ParseFooter(trimPadding(dvplData, config)); err == nil {
+		result.CRC32 = footer.CRC32
+	}
+
+	// Report the planned conversion without touching the disk
+	return !config.DryRun
+}
+
+// writeFile writes a file converted by convertFile and removes the original unless it is kept.
+func (run *processRun) writeFile(file *conversion) {
+	config, result := file.config, &file.result
+	if config.Output != "" || config.OutputTemplate != "" {
+		if err := os.MkdirAll(filepath.Dir(file.writeName), 0755); err != nil {
+			result.Err = fmt.Errorf("preparing output: %w", err)
+			return
+		}
+	}
+
+	// Carry the source modification time over so mtime-based sync tools see unchanged files
+	var modTime time.Time
+	if config.PreserveTimes {
+		modTime = file.info.ModTime()
+	}
+
+	run.ioLimit.acquire()
+	writeStart := time.Now()
+	err := run.retry(func() error {
+		return writeFileAtomic(file.writeName, file.block, modTime)
+	})
+	result.WriteTime = time.Since(writeStart)
+	run.ioLimit.release()
+	if err != nil {
+		result.Err = fmt.Errorf("writing file %s: %w", result.OutputPath, err)
+		return
+	}
+
+	if config.Mode == "compress" && config.SidecarHash != "" {
+		if err := writeSidecar(file.writeName, file.block, config.SidecarHash, modTime); err != nil {
+			result.Err = fmt.Errorf("writing checksum file: %w", err)
+			return
+		}
+	}
+
+	if !config.KeepOriginals && !run.separateOutput() {
+		result.RemoveErr = os.Remove(file.filePath)
+		result.Removed = result.RemoveErr == nil
+	}
+}
+
+// finishFile releases what a file held through its stages, whichever one it stopped at, and returns its result.
+func (run *processRun) finishFile(file *conversion) Result {
+	if file.scratch != nil && !errors.Is(file.result.Err, ErrFileTimeout) {
+		run.scratch.Put(file.scratch)
+	}
+	if file.task != nil {
+		file.task.finish(file.unlock)
+	}
+	return file.result
+}
+
+// isStored reports whether DVPL data holds its block uncompressed.
+func isStored(dvplData []byte) bool {
+	footer, err := dvpl.ParseFooter(dvplData)
+	return err == nil && footer.Type == dvpl.TypeNone
+}
+
+// CountActions returns how many files were compressed and decompressed successfully, which only
+// differ from the success count of CountResults in auto mode.
+func CountActions(results []Result) (compressCount, decompressCount int) {
+	for _, result := range results {
+		if result.Failed() {
+			continue
+		}
+		switch result.Action {
+		case "compress":
+			compressCount++
+		case "decompress":
+			decompressCount++
+		}
+	}
+	return compressCount, decompressCount
+}
+
+// CountStored tallies the successfully compressed files that were stored uncompressed.
+func CountStored(results []Result) (storedCount int) {
+	for _, result := range results {
+		if result.Stored && !result.Failed() {
+			storedCount++
+		}
+	}
+	return storedCount
+}
+
+// modifiedSince reports whether the file was modified after the -since cutoff, which is always the case without one.
+func modifiedSince(info os.FileInfo, config *Config) bool {
+	return config.SinceTime.IsZero() || info.ModTime().After(config.SinceTime)
+}
+
+// tooLarge reports whether the file exceeds the -max-file-size limit, which is never the case without one.
+func tooLarge(info os.FileInfo, config *Config) bool {
+	return config.MaxFileBytes > 0 && info.Size() > config.MaxFileBytes
+}
+
+// newerOutput reports whether the output already exists and was modified after the source file,
+// as happens when a decompressed file is edited and decompress is run again.
+func newerOutput(outputPath string, info os.FileInfo) bool {
+	outputInfo, err := os.Stat(outputPath)
+	return err == nil && outputInfo.ModTime().After(info.ModTime())
+}
+
+// existingOutput leaves an existing result.OutputPath alone when -skip-existing or -overwrite=false ask for it,
+// marking the result as ignored or failed. It reports whether the file must not be converted.
+func existingOutput(result *Result, config *Config) bool {
+	if !config.SkipExisting && config.Overwrite {
+		return false
+	}
+	if _, err := os.Stat(result.OutputPath); err != nil {
+		return false
+	}
+
+	if config.SkipExisting {
+		result.Action = "ignore"
+		result.Reason = "file with existing output"
+	} else {
+		result.Err = fmt.Errorf("%w: %s", ErrOutputExists, result.OutputPath)
+	}
+	return true
+}
+
+// retryDelay is the wait before the first retry of a transient I/O error, doubled for every further attempt.
+const retryDelay = 100 * time.Millisecond
+
+// retry runs op, running it again up to config.Retries times while it fails with a transient error.
+// It gives up early when the run is stopped, returning the last error.
+func (run *processRun) retry(op func() error) error {
+	err := op()
+	delay := retryDelay
+	for attempt := 0; attempt < run.config.Retries && isTransient(err); attempt++ {
+		select {
+		case <-run.ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+		err = op()
+	}
+	return err
+}
+
+// fileTask tracks the -file-timeout budget of one file and its reads and conversions still running.
+// Once the deadline passes, reads stop at their next chunk, but an operation stuck in a system call or
+// inside the codec cannot be interrupted. It is abandoned, and whatever it holds, its stage slot and
+// the file lock, is only released once it actually returns, so timed out files never pile up beyond
+// the limits of the run.
+type fileTask struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	timeout   time.Duration
+	running   sync.WaitGroup // Operations started with a deadline that have not returned yet
+	abandoned bool           // An operation outlived the deadline
+}
+
+// startFile starts the -file-timeout budget of the file about to be read and converted. Without
+// -file-timeout its operations run to completion however long they take.
+func (run *processRun) startFile() *fileTask {
+	task := &fileTask{timeout: run.config.FileTimeout}
+	if task.timeout > 0 {
+		task.ctx, task.cancel = context.WithTimeout(context.Background(), task.timeout)
+	} else {
+		task.ctx, task.cancel = context.WithCancel(context.Background())
+	}
+	return task
+}
+
+// withinDeadline runs op with the context of the file and calls release, if not nil, once op has returned.
+// It returns the error of op, or an error wrapping ErrFileTimeout once the deadline has passed. op may then
+// still be running, and the caller must not use anything op writes to.
+func (task *fileTask) withinDeadline(release func(), op func(ctx context.Context) error) error {
+	if _, ok := task.ctx.Deadline(); !ok {
+		if release != nil {
+			defer release()
+		}
+		return op(task.ctx)
+	}
+
+	done := make(chan error, 1)
+	task.running.Add(1)
+	go func() {
+		defer task.running.Done()
+		err := op(task.ctx)
+		if release != nil {
+			release()
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w after %s", ErrFileTimeout, task.timeout)
+		}
+		return err
+	case <-task.ctx.Done():
+		task.abandoned = true
+		return fmt.Errorf("%w after %s", ErrFileTimeout, task.timeout)
+	}
+}
+
+// finish ends the budget of the file and calls release, if not nil. When an operation was abandoned
+// on timeout, release waits in the background until it has returned.
+func (task *fileTask) finish(release func()) {
+	task.cancel()
+	if release == nil {
+		return
+	}
+	if !task.abandoned {
+		release()
+		return
+	}
+	go func() {
+		task.running.Wait()
+		release()
+	}()
+}
+
+// readChunkSize is how much readAllContext reads between checks of its context.
+const readChunkSize = 1 << 20
+
+// readFileContext reads the whole file at path like os.ReadFile, but gives up with the error of ctx
+// between chunks, so a slow read stops soon after its -file-timeout.
+func readFileContext(ctx context.Context, path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var size int
+	if info, err := file.Stat(); err == nil {
+		size = int(info.Size())
+	}
+	return readAllContext(ctx, file, size)
+}
+
+// readAllContext reads r until EOF in chunks of at most readChunkSize, checking ctx before each one.
+// sizeHint is the expected size, used to allocate the buffer once.
+func readAllContext(ctx context.Context, r io.Reader, sizeHint int) ([]byte, error) {
+	// One byte of room past the hint lets the final read see EOF without growing the buffer
+	data := make([]byte, 0, sizeHint+1)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)]
+		}
+		end := cap(data)
+		if end-len(data) > readChunkSize {
+			end = len(data) + readChunkSize
+		}
+		n, err := r.Read(data[len(data):end])
+		data = data[:len(data)+n]
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isTransient reports whether an I/O error may go away on its own, as timeouts and busy files on network
+// shares do. Format and checksum errors never do, so they are not retried.
+func isTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return false // The -file-timeout budget is spent, another attempt would fail the same way
+	}
+	return err != nil && (os.IsTimeout(err) || errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EAGAIN))
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames it into place once complete,
+// so an interrupted run never leaves a truncated output behind. A non-zero modTime is applied before the rename.
+func writeFileAtomic(name string, data []byte, modTime time.Time) (err error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tempName := tempFile.Name()
+
+	// Remove the temporary file on any failure, leaving the original untouched
+	defer func() {
+		if err != nil {
+			os.Remove(tempName)
+		}
+	}()
+
+	if _, err = tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err = tempFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tempName, 0644); err != nil {
+		return err
+	}
+	if !modTime.IsZero() {
+		if err = os.Chtimes(tempName, time.Now(), modTime); err != nil {
+			return fmt.Errorf("preserving modification time: %w", err)
+		}
+	}
+
+	return os.Rename(tempName, name)
+}
@@ -0,0 +1,867 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+func TestShouldProcess(t *testing.T) {
+	const execPath = "/opt/dvpl_lz4/dvpl_lz4"
+
+	tests := []struct {
+		name    string
+		path    string
+		config  Config
+		process bool
+		reason  string
+	}{
+		{"compress plain file", "/data/a.yaml", Config{Mode: "compress"}, true, ""},
+		{"compress skips dvpl", "/data/a.yaml.dvpl", Config{Mode: "compress"}, false, reasonCompressed},
+		{"decompress dvpl", "/data/a.yaml.dvpl", Config{Mode: "decompress"}, true, ""},
+		{"decompress skips plain file", "/data/a.yaml", Config{Mode: "decompress"}, false, reasonUncompressed},
+		{"other modes convert nothing", "/data/a.yaml", Config{Mode: "verify"}, false, ""},
+		{"own executable", execPath, Config{Mode: "compress"}, false, "own executable file"},
+		{"ignored extension", "/data/game.exe", Config{Mode: "compress", Ignore: ".exe,.dll"}, false, "file with ignored extension"},
+		{"second ignored extension", "/data/lib.dll", Config{Mode: "compress", Ignore: ".exe,.dll"}, false, "file with ignored extension"},
+		{"extension not ignored", "/data/a.yaml", Config{Mode: "compress", Ignore: ".exe,.dll"}, true, ""},
+		{"ignore applies to decompress", "/data/a.exe.dvpl", Config{Mode: "decompress", Ignore: ".dvpl"}, false, "file with ignored extension"},
+		{"include matches", "/data/a.yaml", Config{Mode: "compress", Include: "*.yaml, *.json"}, true, ""},
+		{"include does not match", "/data/a.png", Config{Mode: "compress", Include: "*.yaml, *.json"}, false, "file not matching -include"},
+		{"custom extension decompress", "/data/a.yaml.pak", Config{Mode: "decompress", CompressedExt: ".pak"}, true, ""},
+		{"custom extension compress skips", "/data/a.yaml.pak", Config{Mode: "compress", CompressedExt: ".pak"}, false, reasonCompressed},
+		{"custom extension skips dvpl", "/data/a.yaml.dvpl", Config{Mode: "decompress", CompressedExt: ".pak"}, false, reasonUncompressed},
+		{"uppercase dvpl decompress", "/data/A.YAML.DVPL", Config{Mode: "decompress"}, true, ""},
+		{"uppercase dvpl compress skips", "/data/A.YAML.DVPL", Config{Mode: "compress"}, false, reasonCompressed},
+		{"ignore any case", "/data/GAME.EXE", Config{Mode: "compress", Ignore: ".exe"}, false, "file with ignored extension"},
+		{"include any case", "/data/A.YAML", Config{Mode: "compress", Include: "*.yaml"}, true, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			process, reason := shouldProcess(test.path, &test.config, execPath)
+			if process != test.process || reason != test.reason {
+				t.Errorf("shouldProcess(%q) = %v, %q, want %v, %q", test.path, process, reason, test.process, test.reason)
+			}
+		})
+	}
+}
+
+func TestSumTimings(t *testing.T) {
+	results := []Result{
+		{Action: "compress", InputSize: 100, OutputSize: 40, ReadTime: 2, Elapsed: 5, WriteTime: 3},
+		{Action: "compress", InputSize: 50, OutputSize: 20, ReadTime: 1, Elapsed: 4, WriteTime: 2},
+		{Action: "compress", InputSize: 70, ReadTime: 1, Err: ErrOutputExists},
+		{Action: "ignore", Reason: "file with ignored extension"},
+	}
+
+	want := Timings{BytesRead: 150, BytesWritten: 60, ReadTime: 3, ConvertTime: 9, WriteTime: 5}
+	if got := SumTimings(results); got != want {
+		t.Errorf("SumTimings() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSumByExtension(t *testing.T) {
+	results := []Result{
+		{Path: "a.yaml.dvpl", Action: "decompress", InputSize: 20, OutputSize: 100},
+		{Path: "b.yaml.dvpl", Action: "decompress", InputSize: 30, OutputSize: 90},
+		{Path: "c.png.dvpl", Action: "decompress", InputSize: 95, OutputSize: 100},
+		{Path: "README.dvpl", Action: "decompress", InputSize: 10, OutputSize: 10},
+		{Path: "d.txt.dvpl", Action: "decompress", InputSize: 70, Err: ErrOutputExists},
+		{Path: "e.txt", Action: "ignore"},
+	}
+
+	want := []ExtensionStats{
+		{Extension: "", Files: 1, InputSize: 10, OutputSize: 10},
+		{Extension: ".png", Files: 1, InputSize: 95, OutputSize: 100},
+		{Extension: ".yaml", Files: 2, InputSize: 50, OutputSize: 190},
+	}
+	got := SumByExtension(results, &Config{Mode: "decompress"})
+	if len(got) != len(want) {
+		t.Fatalf("SumByExtension() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SumByExtension()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 4, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		since string
+		want  time.Time
+		valid bool
+	}{
+		{"2024-04-01T00:00:00Z", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), true},
+		{"24h", now.Add(-24 * time.Hour), true},
+		{"90m", now.Add(-90 * time.Minute), true},
+		{"-1h", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseSince(test.since, now)
+		if (err == nil) != test.valid || !got.Equal(test.want) {
+			t.Errorf("parseSince(%q) = %v, %v, want %v, valid %v", test.since, got, err, test.want, test.valid)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size  string
+		want  int64
+		valid bool
+	}{
+		{"4096", 4096, true},
+		{"500MB", 500 << 20, true},
+		{"1.5gb", 3 << 29, true},
+		{"2 K", 2048, true},
+		{"0", 0, false},
+		{"-1MB", 0, false},
+		{"NaN", 0, false},
+		{"lots", 0, false},
+	}
+
+	for _, test := range tests {
+		got, err := parseSize(test.size)
+		if (err == nil) != test.valid || got != test.want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d, valid %v", test.size, got, err, test.want, test.valid)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		retries  int
+		failures []error
+		wantErr  error
+		wantRuns int
+	}{
+		{0, []error{syscall.EBUSY}, syscall.EBUSY, 1},
+		{2, []error{syscall.EBUSY, syscall.EBUSY}, nil, 3},
+		{1, []error{syscall.EBUSY, syscall.EBUSY}, syscall.EBUSY, 2},
+		{3, []error{dvpl.ErrCRC32Mismatch}, dvpl.ErrCRC32Mismatch, 1},
+	}
+
+	for _, test := range tests {
+		run := &processRun{ctx: context.Background(), config: &Config{Retries: test.retries}}
+		runs := 0
+		err := run.retry(func() error {
+			runs++
+			if runs <= len(test.failures) {
+				return test.failures[runs-1]
+			}
+			return nil
+		})
+		if err != test.wantErr || runs != test.wantRuns {
+			t.Errorf("retries %d with %v: err %v after %d runs, want %v after %d", test.retries, test.failures, err, runs, test.wantErr, test.wantRuns)
+		}
+	}
+}
+
+func TestMinRatio(t *testing.T) {
+	// Half random, half zeros: LZ4 shrinks it to about half its size
+	data := make([]byte, 8192)
+	rand.New(rand.NewSource(1)).Read(data[:4096])
+
+	for _, test := range []struct {
+		minRatio float64
+		stored   bool
+	}{{0, false}, {0.8, false}, {0.3, true}} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "half.bin"), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := ProcessFiles(dir, &Config{Mode: "compress", Overwrite: true, MinRatio: test.minRatio})
+		if err != nil || len(results) != 1 || results[0].Failed() {
+			t.Fatalf("min ratio %g: %+v, %v", test.minRatio, results, err)
+		}
+		if results[0].Stored != test.stored || (CountStored(results) == 1) != test.stored {
+			t.Errorf("min ratio %g: stored %v, counted %d, want %v", test.minRatio, results[0].Stored, CountStored(results), test.stored)
+		}
+
+		decompressed, err := dvpl.DecompressFile(filepath.Join(dir, "half.bin.dvpl"))
+		if err != nil || !bytes.Equal(decompressed, data) {
+			t.Errorf("min ratio %g: output does not round-trip: %v", test.minRatio, err)
+		}
+	}
+}
+
+func TestStageThreads(t *testing.T) {
+	counts := []struct {
+		config Config
+		want   int
+	}{
+		{Config{Threads: 3}, 3},
+		{Config{Threads: 3, IOThreads: 8}, 11},
+		{Config{Threads: 3, IOThreads: 8, CPUThreads: 2}, 10},
+	}
+	for _, count := range counts {
+		if got := count.config.workerCount(); got != count.want {
+			t.Errorf("workerCount() with %d/%d/%d threads = %d, want %d", count.config.Threads, count.config.IOThreads, count.config.CPUThreads, got, count.want)
+		}
+	}
+
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.txt", i)), bytes.Repeat([]byte{byte(i)}, 1000), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := ProcessFiles(dir, &Config{Mode: "compress", Overwrite: true, IOThreads: 1, CPUThreads: 2})
+	if success, failure, _ := CountResults(results); err != nil || success != 20 || failure != 0 {
+		t.Errorf("%d succeeded and %d failed, err %v, want 20, 0, nil", success, failure, err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{}
+	for i := 0; i < 30; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+		files[name] = bytes.Repeat([]byte(fmt.Sprintf("line %d\n", i)), 100*i)
+		if err := os.WriteFile(name, files[name], 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wrapped, err := dvpl.CompressDVPL([]byte("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "wrapped.txt"), wrapped, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ignored, converted and dry-run files all leave the stages with their result
+	dryRun, err := ProcessFiles(dir, &Config{Mode: "compress", Overwrite: true, DryRun: true, IOThreads: 1, CPUThreads: 3})
+	if success, failure, ignored := CountResults(dryRun); err != nil || success != 30 || failure != 0 || ignored != 1 {
+		t.Fatalf("dry run: %d succeeded, %d failed, %d ignored, err %v, want 30, 0, 1, nil", success, failure, ignored, err)
+	}
+
+	results, err := ProcessFiles(dir, &Config{Mode: "auto", Overwrite: true, IOThreads: 2, CPUThreads: 1})
+	if success, failure, ignored := CountResults(results); err != nil || success != 30 || failure != 0 || ignored != 1 {
+		t.Fatalf("compress: %d succeeded, %d failed, %d ignored, err %v, want 30, 0, 1, nil", success, failure, ignored, err)
+	}
+	for name, data := range files {
+		decompressed, err := dvpl.DecompressFile(name + ".dvpl")
+		if err != nil || !bytes.Equal(decompressed, data) {
+			t.Errorf("%s does not round-trip through the pipeline: %v", name, err)
+		}
+	}
+
+	// A failure stops the stages like any other run with -fail-fast
+	if err := os.WriteFile(filepath.Join(dir, "broken.bin.dvpl"), []byte("not dvpl"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = ProcessFiles(dir, &Config{Mode: "decompress", Overwrite: true, FailFast: true, Threads: 1, IOThreads: 1, CPUThreads: 1})
+	if err == nil {
+		t.Error("failed file did not stop the run")
+	}
+}
+
+func TestNewerDecompressOutput(t *testing.T) {
+	dir := t.TempDir()
+	dvplPath := filepath.Join(dir, "edited.yaml.dvpl")
+	compressed, err := dvpl.CompressDVPL([]byte("original: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dvplPath, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(dvplPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+	edited := []byte("original: false")
+	if err := os.WriteFile(filepath.Join(dir, "edited.yaml"), edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ProcessFiles(dvplPath, &Config{Mode: "decompress", Overwrite: true, KeepOriginals: true})
+	if err != nil || len(results) != 1 || !results[0].Ignored() || !results[0].Warn {
+		t.Fatalf("decompress over a newer file: %+v, %v, want a warned ignore", results, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "edited.yaml")); !bytes.Equal(data, edited) {
+		t.Error("newer output was overwritten")
+	}
+
+	results, err = ProcessFiles(dvplPath, &Config{Mode: "decompress", Overwrite: true, OverwriteNewer: true, KeepOriginals: true})
+	if success, _, _ := CountResults(results); err != nil || success != 1 {
+		t.Errorf("explicit overwrite: %+v, %v, want 1 success", results, err)
+	}
+}
+
+func TestUppercaseDVPL(t *testing.T) {
+	dir := t.TempDir()
+	compressed, err := dvpl.CompressDVPL([]byte("upper: case"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Config.YAML.DVPL"), compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ProcessFiles(dir, &Config{Mode: "decompress", Overwrite: true})
+	if success, _, _ := CountResults(results); err != nil || success != 1 {
+		t.Fatalf("decompress: %+v, %v, want 1 success", results, err)
+	}
+	if results[0].OutputPath != filepath.Join(dir, "Config.YAML") {
+		t.Errorf("output path = %s, want the name without .DVPL", results[0].OutputPath)
+	}
+}
+
+func TestUnreadableFile(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("file permissions are not enforced for this user")
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"readable.txt", "unreadable.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("permission test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(dir, "unreadable.txt"), 0000); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{Mode: "compress", Threads: 1, Overwrite: true, KeepOriginals: true}
+	results, err := ProcessFiles(dir, config)
+	if err != nil {
+		t.Fatalf("ProcessFiles: %v", err)
+	}
+	if success, failure, _ := CountResults(results); success != 1 || failure != 1 {
+		t.Errorf("compress: %d succeeded and %d failed, want 1 and 1", success, failure)
+	}
+
+	if err := os.Chmod(filepath.Join(dir, "readable.txt.dvpl"), 0000); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := VerifyDVPLFiles(filepath.Join(dir, "readable.txt.dvpl"), &Config{Mode: "verify"})
+	if err != nil || stats.Success != 0 || stats.Failure != 1 {
+		t.Errorf("verify: %d succeeded and %d failed, err %v, want 0, 1, nil", stats.Success, stats.Failure, err)
+	}
+}
+
+func TestUnreadableDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions are not enforced for this user")
+	}
+
+	dir := t.TempDir()
+	for _, name := range []string{"a/first.txt", "b/locked.txt", "c/last.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("walk test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locked := filepath.Join(dir, "b")
+	if err := os.Chmod(locked, 0000); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	results, err := ProcessFiles(dir, &Config{Mode: "compress", Threads: 1, KeepOriginals: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("ProcessFiles: %v", err)
+	}
+	if success, failure, _ := CountResults(results); success != 2 || failure != 1 {
+		t.Errorf("walk: %d succeeded and %d failed, want 2 and 1", success, failure)
+	}
+	for _, result := range results {
+		if result.Failed() && (result.Path != locked || FailureKind(result.Err) != "permission denied") {
+			t.Errorf("unexpected failure %s: %v", result.Path, result.Err)
+		}
+	}
+
+	results, err = ProcessFiles(dir, &Config{Mode: "compress", Threads: 1, KeepOriginals: true, DryRun: true, FailFast: true})
+	if err == nil {
+		t.Errorf("fail-fast walk: %+v, want an error", results)
+	}
+	for _, result := range results {
+		if strings.HasSuffix(result.Path, "last.txt") {
+			t.Error("fail-fast walk continued past the unreadable directory")
+		}
+	}
+}
+
+func TestTinyDVPLFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "x.dvpl")
+	if err := os.WriteFile(path, []byte("stray text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ProcessFiles(dir, &Config{Mode: "decompress", Threads: 1, Overwrite: true})
+	if err != nil {
+		t.Fatalf("ProcessFiles: %v", err)
+	}
+	if _, failure, _ := CountResults(results); failure != 1 || !errors.Is(results[0].Err, dvpl.ErrFileTooSmall) {
+		t.Errorf("decompress: %d failed with %v, want 1 failure with %v", failure, results[0].Err, dvpl.ErrFileTooSmall)
+	}
+
+	stats, err := VerifyDVPLFiles(dir, &Config{Mode: "verify"})
+	if err != nil || stats.Success != 0 || stats.Failure != 1 {
+		t.Errorf("verify: %d succeeded and %d failed, err %v, want 0, 1, nil", stats.Success, stats.Failure, err)
+	}
+}
+
+func TestExcludeDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"keep/a.txt", "backup/b.txt", "backup/deep/c.txt", "keep/.git/d.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("exclude test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{Mode: "compress", Threads: 1, Overwrite: true, ExcludeDir: "backup, .g*"}
+	results, err := ProcessFiles(dir, config)
+	if err != nil {
+		t.Fatalf("ProcessFiles: %v", err)
+	}
+	if success, failure, ignored := CountResults(results); success != 1 || failure != 0 || ignored != 2 {
+		t.Errorf("%d succeeded, %d failed and %d ignored, want 1, 0 and 2", success, failure, ignored)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "backup", "deep", "c.txt")); err != nil {
+		t.Errorf("file below excluded directory was touched: %v", err)
+	}
+}
+
+func TestSkipHidden(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.yaml", ".hidden.yaml", ".git/config.yaml", "sub/.git/objects/b.yaml", "sub/c.yaml"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("hidden: false"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := ProcessFiles(dir, &Config{Mode: "compress", KeepOriginals: true, SkipHidden: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if success, failure, ignored := CountResults(results); success != 2 || failure != 0 || ignored != 3 {
+		t.Errorf("%d succeeded, %d failed and %d ignored, want 2, 0 and 3: %+v", success, failure, ignored, results)
+	}
+	for _, name := range []string{".hidden.yaml.dvpl", ".git/config.yaml.dvpl", "sub/.git/objects/b.yaml.dvpl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was written for a hidden file: %v", name, err)
+		}
+	}
+
+	// Without the flag dotfiles are converted as before
+	results, err = ProcessFiles(dir, &Config{Mode: "compress", KeepOriginals: true, Overwrite: true})
+	if success, _, _ := CountResults(results); err != nil || success != 5 {
+		t.Errorf("without -skip-hidden: %d succeeded, %v, want all 5 files", success, err)
+	}
+}
+
+func TestLockedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "busy.txt")
+	if err := os.WriteFile(path, []byte("busy"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ProcessFiles(dir, &Config{Mode: "compress", KeepOriginals: true})
+	if err != nil || len(results) != 2 {
+		t.Fatalf("compress with a held lock: %+v, %v, want 2 results", results, err)
+	}
+	for _, result := range results {
+		if !result.Ignored() {
+			t.Errorf("%s was %s, want it ignored", result.Path, result.Action)
+		}
+	}
+	if _, err := os.Stat(path + ".dvpl"); !os.IsNotExist(err) {
+		t.Error("locked file was compressed")
+	}
+
+	results, err = ProcessFiles(path, &Config{Mode: "compress", KeepOriginals: true, NoLock: true})
+	if success, _, _ := CountResults(results); err != nil || success != 1 {
+		t.Errorf("compress with -no-lock: %+v, %v, want 1 success", results, err)
+	}
+
+	unlock()
+	results, err = ProcessFiles(path+".dvpl", &Config{Mode: "decompress", Overwrite: true, OverwriteNewer: true, KeepOriginals: true})
+	if success, _, _ := CountResults(results); err != nil || success != 1 {
+		t.Errorf("decompress after unlock: %+v, %v, want 1 success", results, err)
+	}
+	if _, err := os.Stat(path + ".dvpl" + lockSuffix); !os.IsNotExist(err) {
+		t.Error("lock file left behind after converting")
+	}
+}
+
+func TestOrder(t *testing.T) {
+	dir := t.TempDir()
+	for name, size := range map[string]int{"a.txt": 300, "b/c.txt": 100, "d.txt": 200} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, bytes.Repeat([]byte("x"), size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for order, want := range map[string][]string{
+		"size-desc": {"a.txt", "d.txt", "b/c.txt"},
+		"size-asc":  {"b/c.txt", "d.txt", "a.txt"},
+		"name":      {"a.txt", "b/c.txt", "d.txt"},
+	} {
+		results, err := ProcessFiles(dir, &Config{Mode: "compress", KeepOriginals: true, DryRun: true, Threads: 1, Order: order})
+		if err != nil || len(results) != len(want) {
+			t.Fatalf("%s: %+v, %v", order, results, err)
+		}
+		for i, result := range results {
+			if rel, _ := filepath.Rel(dir, result.Path); rel != filepath.FromSlash(want[i]) {
+				t.Errorf("%s: file %d is %s, want %s", order, i, rel, want[i])
+			}
+		}
+	}
+}
+
+func TestOutputTemplate(t *testing.T) {
+	for _, template := range []string{"{dir}/{name}", "{stem}.bak{ext}", "out/{name}"} {
+		if err := validateOutputTemplate(template); err != nil {
+			t.Errorf("validateOutputTemplate(%q) = %v", template, err)
+		}
+	}
+	for _, template := range []string{"{dir}/{base}", "{dir}/out", ""} {
+		if err := validateOutputTemplate(template); err == nil {
+			t.Errorf("validateOutputTemplate(%q) accepted an invalid template", template)
+		}
+	}
+
+	dir := t.TempDir()
+	compressed, err := dvpl.CompressDVPL([]byte("templated: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dvplPath := filepath.Join(dir, "config.yaml.dvpl")
+	if err := os.WriteFile(dvplPath, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ProcessFiles(dir, &Config{Mode: "decompress", KeepOriginals: true, OutputTemplate: "{dir}/extracted/{stem}.copy{ext}"})
+	want := filepath.Join(dir, "extracted", "config.copy.yaml")
+	if err != nil || len(results) != 1 || results[0].OutputPath != want {
+		t.Fatalf("decompress with a template: %+v, %v, want output %s", results, err, want)
+	}
+	if data, err := os.ReadFile(want); err != nil || string(data) != "templated: true" {
+		t.Errorf("templated output: %q, %v", data, err)
+	}
+
+	results, err = ProcessFiles(dvplPath, &Config{Mode: "decompress", KeepOriginals: true, OutputTemplate: "{dir}/{name}.dvpl"})
+	if _, failures, _ := CountResults(results); err != nil || failures != 1 {
+		t.Errorf("template naming the source itself: %+v, %v, want 1 failure", results, err)
+	}
+}
+
+func TestPruneEmpty(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/b/moved.txt", "a/kept.dat", "c/moved.txt", "c/keep/other.txt", "untouched/.keep"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("prune test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Outputs go to sibling directories, so the directories of the originals are emptied
+	config := &Config{Mode: "compress", Include: "moved.txt", PruneEmpty: true, OutputTemplate: "{dir}.out/{name}"}
+	results, err := ProcessFiles(dir, config)
+	if success, _, _ := CountResults(results); err != nil || success != 2 {
+		t.Fatalf("compress: %+v, %v, want 2 successes", results, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "a", "b")); !os.IsNotExist(err) {
+		t.Errorf("a/b was not pruned: %v", err)
+	}
+	// Directories holding other files, and ones the run emptied nothing from, are kept
+	for _, kept := range []string{".", "a", "c", "c/keep", "untouched", "empty"} {
+		if _, err := os.Stat(filepath.Join(dir, kept)); err != nil {
+			t.Errorf("%s was removed: %v", kept, err)
+		}
+	}
+}
+
+func TestWithinDeadline(t *testing.T) {
+	run := &processRun{config: &Config{FileTimeout: 10 * time.Millisecond}}
+	limit := newStageLimit(1)
+
+	// A stalled operation is abandoned, but keeps its stage slot and the file lock until it returns
+	task := run.startFile()
+	stalled := make(chan struct{})
+	limit.acquire()
+	err := task.withinDeadline(limit.release, func(ctx context.Context) error {
+		<-stalled
+		return nil
+	})
+	if !errors.Is(err, ErrFileTimeout) {
+		t.Errorf("stalled operation: %v, want ErrFileTimeout", err)
+	}
+	unlocked := make(chan struct{})
+	task.finish(func() { close(unlocked) })
+	select {
+	case limit <- struct{}{}:
+		t.Error("stage slot released while the operation was still running")
+	case <-unlocked:
+		t.Error("file unlocked while the operation was still running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(stalled)
+	<-unlocked
+	limit.acquire()
+	limit.release()
+
+	// An operation that checks its context stops at the deadline
+	task = run.startFile()
+	err = task.withinDeadline(nil, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, ErrFileTimeout) {
+		t.Errorf("cancellable operation: %v, want ErrFileTimeout", err)
+	}
+	task.finish(nil)
+
+	task = run.startFile()
+	if err := task.withinDeadline(nil, func(context.Context) error { return os.ErrNotExist }); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("failing operation: %v, want its own error", err)
+	}
+	task.finish(nil)
+
+	run.config.FileTimeout = 0
+	task = run.startFile()
+	if err := task.withinDeadline(nil, func(context.Context) error { return nil }); err != nil {
+		t.Errorf("without a deadline: %v", err)
+	}
+	task.finish(nil)
+}
+
+func TestReadFileContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.yaml")
+	data := bytes.Repeat([]byte("read: chunked\n"), 3*readChunkSize/14)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := readFileContext(context.Background(), path)
+	if err != nil || !bytes.Equal(read, data) {
+		t.Errorf("read %d bytes, %v, want %d", len(read), err, len(data))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := readFileContext(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled read: %v, want context.Canceled", err)
+	}
+}
+
+func TestAcceleration(t *testing.T) {
+	dir := t.TempDir()
+	original := bytes.Repeat([]byte("acceleration: 8\n"), 4096)
+	path := filepath.Join(dir, "a.yaml")
+	if err := os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ProcessFiles(path, &Config{Mode: "compress", Acceleration: 8})
+	if success, failure, _ := CountResults(results); err != nil || success != 1 || failure != 0 {
+		t.Fatalf("%d compressed and %d failed, err %v, want 1, 0, nil", success, failure, err)
+	}
+	results, err = ProcessFiles(path+".dvpl", &Config{Mode: "decompress"})
+	if success, _, _ := CountResults(results); err != nil || success != 1 {
+		t.Fatalf("decompressing: %d succeeded, err %v", success, err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, original) {
+		t.Errorf("accelerated file does not round-trip: %v", err)
+	}
+
+	// Acceleration 0 is the zero value of configs read from YAML and selects the default compressor
+	results, err = ProcessFiles(path, &Config{Mode: "compress", Acceleration: 0, KeepOriginals: true})
+	if success, _, _ := CountResults(results); err != nil || success != 1 {
+		t.Fatalf("compressing with acceleration 0: %d succeeded, err %v", success, err)
+	}
+	want, err := dvpl.CompressDVPL(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path + ".dvpl"); err != nil || !bytes.Equal(data, want) {
+		t.Errorf("acceleration 0 output differs from CompressDVPL: %v", err)
+	}
+}
+
+func TestModeHint(t *testing.T) {
+	dir := t.TempDir()
+	compressed, err := dvpl.CompressDVPL([]byte("hint: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dvplPath := filepath.Join(dir, "a.yaml.dvpl")
+	if err := os.WriteFile(dvplPath, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ProcessFiles(dvplPath, &Config{Mode: "compress"})
+	if err != nil || len(results) != 1 || results[0].Reason != "already compressed file (use -mode decompress?)" {
+		t.Errorf("compress on a dvpl file: %+v, %v, want a hint to decompress", results, err)
+	}
+
+	results, err = VerifyFilesContext(context.Background(), filepath.Join(dir, "a.yaml.dvpl"), &Config{Mode: "verify", CompressedExt: ".pak"})
+	if err != nil || len(results) != 1 || results[0].Reason != reasonUncompressed {
+		t.Errorf("verify on a file without the extension: %+v, %v, want the reason without a mode hint", results, err)
+	}
+}
+
+func TestSidecarHash(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.yaml")
+	if err := os.WriteFile(path, []byte("sidecar: true"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ProcessFiles(dir, &Config{Mode: "compress", KeepOriginals: true, SidecarHash: "castagnoli"})
+	if success, _, _ := CountResults(results); err != nil || success != 1 {
+		t.Fatalf("compress: %+v, %v, want 1 success", results, err)
+	}
+	compressed, err := os.ReadFile(path + ".dvpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sidecar, err := os.ReadFile(path + ".dvpl.crc")
+	want := fmt.Sprintf("%08x  a.yaml.dvpl\n", crc32.Checksum(compressed, crc32.MakeTable(crc32.Castagnoli)))
+	if err != nil || string(sidecar) != want {
+		t.Errorf("sidecar = %q, %v, want %q", sidecar, err, want)
+	}
+	// The footer keeps the IEEE CRC32 the game checks
+	if _, err := dvpl.DecompressDVPL(compressed); err != nil {
+		t.Errorf("compressed file: %v", err)
+	}
+
+	// A second run neither compresses the sidecar nor trips over it
+	results, err = ProcessFiles(dir, &Config{Mode: "compress", KeepOriginals: true, SkipExisting: true, SidecarHash: "castagnoli"})
+	for _, result := range results {
+		if strings.HasSuffix(result.Path, ".crc") && result.Reason != "checksum sidecar file" {
+			t.Errorf("second run handled the sidecar as %+v", result)
+		}
+	}
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAutoMode(t *testing.T) {
+	dir := t.TempDir()
+	compressed, err := dvpl.CompressDVPL([]byte("auto: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"a.yaml":      []byte("auto: true"),
+		"b.yaml.dvpl": compressed,
+		"c.yaml":      []byte("auto: true"),
+		"c.yaml.dvpl": compressed,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := ProcessFiles(dir, &Config{Mode: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if compressCount, decompressCount := CountActions(results); compressCount != 1 || decompressCount != 1 {
+		t.Errorf("CountActions = %d, %d, want 1 compressed and 1 decompressed: %+v", compressCount, decompressCount, results)
+	}
+	if _, _, ignored := CountResults(results); ignored != 2 {
+		t.Errorf("ignored = %d, want both files of the pair: %+v", ignored, results)
+	}
+
+	for _, name := range []string{"a.yaml.dvpl", "b.yaml", "c.yaml", "c.yaml.dvpl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"a.yaml", "b.yaml.dvpl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after conversion: %v", name, err)
+		}
+	}
+}
+
+func TestRelativeExecutable(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Dir(executable)); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	// The test binary is found below ".", "./" and its own name, never by the absolute path os.Executable returns
+	for _, path := range []string{".", "./", filepath.Base(executable)} {
+		results, err := ListFilesContext(context.Background(), path, &Config{Mode: "compress"})
+		if err != nil {
+			t.Fatalf("%q: %v", path, err)
+		}
+		found := false
+		for _, result := range results {
+			if result.Path == executable {
+				found = result.Reason == "own executable file"
+			}
+		}
+		if !found {
+			t.Errorf("%q: the executable was not ignored as such: %+v", path, results)
+		}
+	}
+}
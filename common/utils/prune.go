@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// pruneEmptyDirs removes the directories below root that the run left empty by deleting originals, then
+// the parents those removals left empty in turn, deepest first. Directories that still hold anything,
+// and root itself, are kept. It returns the removed directories.
+func pruneEmptyDirs(root string, results []Result) ([]string, error) {
+	pending := make(map[string]bool)
+	for _, result := range results {
+		if result.Removed {
+			pending[filepath.Dir(result.Path)] = true
+		}
+	}
+
+	var removed []string
+	var errs []error
+	for len(pending) > 0 {
+		dirs := make([]string, 0, len(pending))
+		for dir := range pending {
+			dirs = append(dirs, dir)
+		}
+		sort.Slice(dirs, func(i, j int) bool { return len(dirs[i]) > len(dirs[j]) })
+		pending = make(map[string]bool)
+
+		for _, dir := range dirs {
+			if rel, err := filepath.Rel(root, dir); err != nil || rel == "." || !filepath.IsLocal(rel) {
+				continue
+			}
+			if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+				continue
+			}
+			if err := os.Remove(dir); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			removed = append(removed, dir)
+			pending[filepath.Dir(dir)] = true
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return removed, fmt.Errorf("pruning empty directories: %w", err)
+	}
+	return removed, nil
+}
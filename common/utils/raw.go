@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+// Extensions of the raw LZ4 block written by raw-extract and of the sidecar holding its original size.
+const (
+	rawBlockExt = ".lz4block"
+	rawSizeExt  = ".size"
+)
+
+// RawExtractFilesContext writes the raw LZ4 block of every .dvpl file in directoryOrFile, without its
+// footer, to a .lz4block file next to it or below config.Output, for separate LZ4 block decoders. The
+// size the block decodes to is written to a .lz4block.size sidecar, which raw-wrap reads back. The
+// .dvpl files are never modified or deleted, and files stored without LZ4 compression fail.
+func RawExtractFilesContext(ctx context.Context, directoryOrFile string, config *Config) ([]Result, error) {
+	return walkFiles(ctx, directoryOrFile, config, (*processRun).rawExtractFile)
+}
+
+// RawWrapFilesContext builds a .dvpl file from every .lz4block file in directoryOrFile and the original
+// size in its .lz4block.size sidecar, the reverse of RawExtractFilesContext. The blocks are checked to
+// decode to that size first and are never modified or deleted.
+func RawWrapFilesContext(ctx context.Context, directoryOrFile string, config *Config) ([]Result, error) {
+	return walkFiles(ctx, directoryOrFile, config, (*processRun).rawWrapFile)
+}
+
+// rawExtractFile writes the raw block and size sidecar of a single .dvpl file.
+func (run *processRun) rawExtractFile(path string, info os.FileInfo) Result {
+	config := run.config
+	result := Result{Path: path, Action: config.Mode}
+
+	// Raw extraction picks the same files decompress mode would
+	modeConfig := *config
+	modeConfig.Mode = "decompress"
+	if process, reason := shouldProcess(path, &modeConfig, run.executablePath); !process {
+		result.Action = "ignore"
+		result.Reason = reason
+		return result
+	}
+
+	newName, err := run.outputPath(config.trimCompressedExt(path) + rawBlockExt)
+	if err != nil {
+		result.Err = fmt.Errorf("preparing output: %w", err)
+		return result
+	}
+	result.OutputPath = newName
+
+	if existingOutput(&result, config) {
+		return result
+	}
+
+	readStart := time.Now()
+	fileData, err := os.ReadFile(path)
+	result.ReadTime = time.Since(readStart)
+	if err != nil {
+		result.Err = fmt.Errorf("reading file: %w", err)
+		return result
+	}
+	result.InputSize = int64(len(fileData))
+
+	block, originalSize, err := dvpl.RawBlock(trimPadding(fileData, config))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.OutputSize = int64(len(block))
+
+	if config.DryRun {
+		return result
+	}
+	run.writeRawOutput(&result, info, block, []byte(strconv.FormatUint(uint64(originalSize), 10)+"\n"))
+	return result
+}
+
+// rawWrapFile builds the .dvpl file of a single .lz4block file.
+func (run *processRun) rawWrapFile(path string, info os.FileInfo) Result {
+	config := run.config
+	result := Result{Path: path, Action: config.Mode}
+
+	if !strings.HasSuffix(path, rawBlockExt) {
+		result.Action = "ignore"
+		result.Reason = "file without the " + rawBlockExt + " extension"
+		return result
+	}
+	modeConfig := *config
+	modeConfig.Mode = "compress"
+	if process, reason := shouldProcess(path, &modeConfig, run.executablePath); !process {
+		result.Action = "ignore"
+		result.Reason = reason
+		return result
+	}
+
+	newName, err := run.outputPath(strings.TrimSuffix(path, rawBlockExt) + config.compressedExt())
+	if err != nil {
+		result.Err = fmt.Errorf("preparing output: %w", err)
+		return result
+	}
+	result.OutputPath = newName
+
+	if existingOutput(&result, config) {
+		return result
+	}
+
+	sizeData, err := os.ReadFile(path + rawSizeExt)
+	if err != nil {
+		result.Err = fmt.Errorf("reading original size: %w", err)
+		return result
+	}
+	originalSize, err := strconv.ParseUint(strings.TrimSpace(string(sizeData)), 10, 32)
+	if err != nil {
+		result.Err = fmt.Errorf("reading original size: %w", err)
+		return result
+	}
+
+	readStart := time.Now()
+	block, err := os.ReadFile(path)
+	result.ReadTime = time.Since(readStart)
+	if err != nil {
+		result.Err = fmt.Errorf("reading file: %w", err)
+		return result
+	}
+	result.InputSize = int64(len(block))
+
+	wrapped, err := dvpl.WrapRawBlock(block, uint32(originalSize))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.OutputSize = int64(len(wrapped))
+
+	if config.DryRun {
+		return result
+	}
+	run.writeRawOutput(&result, info, wrapped, nil)
+	return result
+}
+
+// writeRawOutput writes data to result.OutputPath, followed by sizeData to its size sidecar unless it is
+// nil, recording the time taken and any error in result.
+func (run *processRun) writeRawOutput(result *Result, info os.FileInfo, data, sizeData []byte) {
+	config := run.config
+	if config.Output != "" {
+		if err := os.MkdirAll(filepath.Dir(result.OutputPath), 0755); err != nil {
+			result.Err = fmt.Errorf("preparing output: %w", err)
+			return
+		}
+	}
+
+	var modTime time.Time
+	if config.PreserveTimes {
+		modTime = info.ModTime()
+	}
+
+	writeStart := time.Now()
+	err := writeFileAtomic(result.OutputPath, data, modTime)
+	if err == nil && sizeData != nil {
+		err = writeFileAtomic(result.OutputPath+rawSizeExt, sizeData, modTime)
+	}
+	result.WriteTime = time.Since(writeStart)
+	if err != nil {
+		result.Err = fmt.Errorf("writing file %s: %w", result.OutputPath, err)
+	}
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+func TestRawExtractWrap(t *testing.T) {
+	dir := t.TempDir()
+	original := bytes.Repeat([]byte("raw block "), 200)
+	compressed, err := dvpl.CompressDVPL(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dvplPath := filepath.Join(dir, "a.yaml.dvpl")
+	if err := os.WriteFile(dvplPath, compressed, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := RawExtractFilesContext(context.Background(), dir, &Config{Mode: "raw-extract"})
+	if success, _, _ := CountResults(results); err != nil || success != 1 {
+		t.Fatalf("raw-extract: %+v, %v, want 1 success", results, err)
+	}
+	block, err := os.ReadFile(filepath.Join(dir, "a.yaml.lz4block"))
+	if err != nil || !bytes.Equal(block, compressed[:len(compressed)-dvpl.FooterSize]) {
+		t.Fatalf("raw block = %d bytes, %v, want the block without the footer", len(block), err)
+	}
+	size, err := os.ReadFile(filepath.Join(dir, "a.yaml.lz4block.size"))
+	if err != nil || string(size) != "2000\n" {
+		t.Fatalf("size sidecar = %q, %v, want 2000", size, err)
+	}
+
+	// Wrapping the block again rebuilds the original .dvpl file
+	if err := os.Remove(dvplPath); err != nil {
+		t.Fatal(err)
+	}
+	results, err = RawWrapFilesContext(context.Background(), dir, &Config{Mode: "raw-wrap"})
+	if success, _, ignored := CountResults(results); err != nil || success != 1 || ignored != 1 {
+		t.Fatalf("raw-wrap: %+v, %v, want 1 success and the size sidecar ignored", results, err)
+	}
+	wrapped, err := os.ReadFile(dvplPath)
+	if err != nil || !bytes.Equal(wrapped, compressed) {
+		t.Errorf("wrapped file differs from the original .dvpl file: %v", err)
+	}
+}
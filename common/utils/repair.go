@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+// RepairFilesContext rewrites the footers of the .dvpl files in directoryOrFile whose blocks are intact
+// but whose footers record wrong sizes or CRC32s, see dvpl.RepairDVPL. Files are walked and filtered
+// like ProcessFilesContext and replaced in place. Each repaired file gets a Result with the "repair"
+// action; files that already decompress cleanly are ignored and files whose block cannot be decoded fail.
+func RepairFilesContext(ctx context.Context, directoryOrFile string, config *Config) ([]Result, error) {
+	return walkFiles(ctx, directoryOrFile, config, (*processRun).repairFile)
+}
+
+// repairFile rewrites the footer of a single .dvpl file when it needs repairing.
+func (run *processRun) repairFile(path string, info os.FileInfo) Result {
+	config := run.config
+	result := Result{Path: path, Action: "repair"}
+
+	// Repair picks the same files decompress mode would
+	modeConfig := *config
+	modeConfig.Mode = "decompress"
+	if process, reason := shouldProcess(path, &modeConfig, run.executablePath); !process {
+		result.Action = "ignore"
+		result.Reason = reason
+		return result
+	}
+
+	readStart := time.Now()
+	fileData, err := os.ReadFile(path)
+	result.ReadTime = time.Since(readStart)
+	if err != nil {
+		result.Err = fmt.Errorf("reading file: %w", err)
+		return result
+	}
+	result.InputSize = int64(len(fileData))
+
+	repairStart := time.Now()
+	repaired, changed, err := dvpl.RepairDVPL(trimPadding(fileData, config))
+	result.Elapsed = time.Since(repairStart)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if !changed {
+		result.Action = "ignore"
+		result.Reason = "file with a valid footer"
+		return result
+	}
+	result.OutputPath = path
+	result.OutputSize = int64(len(repaired))
+
+	if config.DryRun {
+		return result
+	}
+
+	var modTime time.Time
+	if config.PreserveTimes {
+		modTime = info.ModTime()
+	}
+
+	writeStart := time.Now()
+	err = writeFileAtomic(path, repaired, modTime)
+	result.WriteTime = time.Since(writeStart)
+	if err != nil {
+		result.Err = fmt.Errorf("writing file %s: %w", path, err)
+	}
+	return result
+}
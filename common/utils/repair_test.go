@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+func TestRepairFiles(t *testing.T) {
+	dir := t.TempDir()
+	original := bytes.Repeat([]byte("repair: true\n"), 50)
+	compressed, err := dvpl.CompressDVPL(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A footer with its CRC32 zeroed, a valid file and a file that is not DVPL data at all
+	broken := append([]byte(nil), compressed...)
+	copy(broken[len(broken)-dvpl.FooterSize+8:], []byte{0, 0, 0, 0})
+	files := map[string][]byte{
+		"broken.yaml.dvpl": broken,
+		"valid.yaml.dvpl":  compressed,
+		"junk.yaml.dvpl":   []byte("junk"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := RepairFilesContext(context.Background(), dir, &Config{Mode: "repair"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired, failed, ignored := CountResults(results); repaired != 1 || failed != 1 || ignored != 1 {
+		t.Errorf("CountResults = %d, %d, %d, want 1 repaired, 1 unrepairable and 1 ignored: %+v", repaired, failed, ignored, results)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "broken.yaml.dvpl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decompressed, err := dvpl.DecompressDVPL(data); err != nil || !bytes.Equal(decompressed, original) {
+		t.Errorf("repaired file does not decompress to the original data: %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sidecarExt is appended to the name of a compressed file to name its -sidecar-hash checksum file.
+const sidecarExt = ".crc"
+
+// sidecarTables are the CRC32 polynomials accepted by -sidecar-hash, keyed by name.
+var sidecarTables = map[string]*crc32.Table{
+	"ieee":       crc32.IEEETable,
+	"castagnoli": crc32.MakeTable(crc32.Castagnoli),
+}
+
+// writeSidecar writes the CRC32 of data, the content of the file name, to name plus sidecarExt as a
+// "<crc32>  <file name>" line. It is an external integrity check only, the DVPL footer always keeps
+// the IEEE CRC32 the game reads.
+func writeSidecar(name string, data []byte, algorithm string, modTime time.Time) error {
+	sum := crc32.Checksum(data, sidecarTables[algorithm])
+	line := fmt.Sprintf("%08x  %s\n", sum, filepath.Base(name))
+	return writeFileAtomic(name+sidecarExt, []byte(line), modTime)
+}
+
+// isSidecar reports whether path is a checksum file written by -sidecar-hash next to a compressed file.
+func (config *Config) isSidecar(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), strings.ToLower(config.compressedExt()+sidecarExt))
+}
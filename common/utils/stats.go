@@ -0,0 +1,60 @@
+package utils
+
+import "sync/atomic"
+
+// Stats counts the files of a run by outcome. A run shares a single *Stats with everything that handles
+// its files, serially or from concurrent workers, and every update is atomic, so the counts stay
+// consistent and can be read with Snapshot while the run is still going.
+type Stats struct {
+	Success int64 // Files converted, verified, matched or read successfully
+	Failure int64 // Files that failed
+	Ignored int64 // Files skipped by the mode or the filters
+}
+
+// Record counts result under its outcome.
+func (stats *Stats) Record(result Result) {
+	switch {
+	case result.Failed():
+		stats.addFailure()
+	case result.Ignored():
+		stats.addIgnored()
+	default:
+		stats.addSuccess()
+	}
+}
+
+func (stats *Stats) addSuccess() { atomic.AddInt64(&stats.Success, 1) }
+func (stats *Stats) addFailure() { atomic.AddInt64(&stats.Failure, 1) }
+func (stats *Stats) addIgnored() { atomic.AddInt64(&stats.Ignored, 1) }
+
+// Snapshot returns a copy of the counts that is safe to take while other goroutines update them.
+func (stats *Stats) Snapshot() Stats {
+	return Stats{
+		Success: atomic.LoadInt64(&stats.Success),
+		Failure: atomic.LoadInt64(&stats.Failure),
+		Ignored: atomic.LoadInt64(&stats.Ignored),
+	}
+}
+
+// Add adds the counts of other, read with Snapshot, to stats.
+func (stats *Stats) Add(other *Stats) {
+	snapshot := other.Snapshot()
+	atomic.AddInt64(&stats.Success, snapshot.Success)
+	atomic.AddInt64(&stats.Failure, snapshot.Failure)
+	atomic.AddInt64(&stats.Ignored, snapshot.Ignored)
+}
+
+// Counts returns a snapshot of the counts as ints, in the order CountResults returns them.
+func (stats *Stats) Counts() (successCount, failureCount, ignoredCount int) {
+	snapshot := stats.Snapshot()
+	return int(snapshot.Success), int(snapshot.Failure), int(snapshot.Ignored)
+}
+
+// ResultStats counts results by outcome, giving the Stats of a run that returned per-file results.
+func ResultStats(results []Result) Stats {
+	var stats Stats
+	for _, result := range results {
+		stats.Record(result)
+	}
+	return stats
+}
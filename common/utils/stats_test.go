@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStatsConcurrent(t *testing.T) {
+	var stats Stats
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				stats.Record(Result{Action: "compress"})
+				stats.Record(Result{Action: "compress", Err: errors.New("failed")})
+				stats.Record(Result{Action: "ignore"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := stats.Snapshot(); got != (Stats{Success: 8000, Failure: 8000, Ignored: 8000}) {
+		t.Errorf("Snapshot = %+v, want 8000 of each outcome", got)
+	}
+}
+
+func TestStatsAdd(t *testing.T) {
+	part := ResultStats([]Result{{Action: "verify"}, {Action: "ignore"}, {Action: "verify", Err: errors.New("failed")}})
+
+	var total Stats
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			total.Add(&part)
+		}()
+	}
+	wg.Wait()
+
+	if success, failure, ignored := total.Counts(); success != 8 || failure != 8 || ignored != 8 {
+		t.Errorf("Counts = %d, %d, %d, want 8 of each outcome", success, failure, ignored)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.yaml", "c.yaml.dvpl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("progress: true"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	progress := &Stats{}
+	results, err := ProcessFiles(dir, &Config{Mode: "compress", KeepOriginals: true, Threads: 4, Progress: progress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	success, failure, ignored := CountResults(results)
+	if got, want := progress.Snapshot(), (Stats{Success: int64(success), Failure: int64(failure), Ignored: int64(ignored)}); got != want || success != 2 {
+		t.Errorf("Progress = %+v, want %+v from 2 compressed files", got, want)
+	}
+}
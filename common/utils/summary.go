@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// PrintConversionDetails prints what follows the summary line of a compress, decompress or auto run:
+// the split between compressed and decompressed files in auto mode, how many files -min-ratio stored
+// uncompressed, the -stats table and, in verbose mode, the byte counts and timings. Quiet runs print
+// only the summary line, so none of it is written under -quiet.
+func PrintConversionDetails(results []Result, config *Config) {
+	if config.Quiet {
+		return
+	}
+	compressCount, decompressCount := CountActions(results)
+	if config.Mode == "auto" {
+		fmt.Fprintf(Output, "Compressed: %d, decompressed: %d\n", compressCount, decompressCount)
+	}
+	if config.MinRatio > 0 && config.Mode != "decompress" {
+		storedCount := CountStored(results)
+		fmt.Fprintf(Output, "LZ4 compressed: %d, stored uncompressed: %d\n", compressCount-storedCount, storedCount)
+	}
+	if config.Stats {
+		printExtensionStats(SumByExtension(results, config))
+	}
+	if config.Verbose {
+		printTimings(SumTimings(results), config)
+	}
+}
+
+// printTimings reports where a verbose conversion run spent its time, to show whether it was I/O or CPU bound.
+func printTimings(timings Timings, config *Config) {
+	written := "written"
+	if config.DryRun {
+		written = "that would be written"
+	}
+	fmt.Fprintf(Output, "\nBytes read: %s, bytes %s: %s\n", FormatSize(timings.BytesRead), written, FormatSize(timings.BytesWritten))
+	converting := config.Mode + "ing"
+	if config.Mode == "auto" {
+		converting = "converting"
+	}
+	fmt.Fprintf(Output, "Time reading: %s, %s: %s, writing: %s (summed across threads)\n", timings.ReadTime.Round(time.Millisecond), converting, timings.ConvertTime.Round(time.Millisecond), timings.WriteTime.Round(time.Millisecond))
+}
+
+// printExtensionStats prints one line per extension, e.g. ".yaml: 412 files, 80.0 MB -> 22.0 MB".
+func printExtensionStats(stats []ExtensionStats) {
+	fmt.Fprintf(Output, "\n")
+	for _, extension := range stats {
+		name := extension.Extension
+		if name == "" {
+			name = "(no extension)"
+		}
+		fmt.Fprintf(Output, "%s: %d files, %s -> %s\n", name, extension.Files, FormatSize(extension.InputSize), FormatSize(extension.OutputSize))
+	}
+}
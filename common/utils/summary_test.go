@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintConversionDetailsQuiet(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.yaml"), []byte(strings.Repeat("quiet: true\n", 64)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ProcessFiles(dir, &Config{Mode: "auto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	defer func(previous io.Writer) { Output = previous }(Output)
+	Output = &output
+
+	config := &Config{Mode: "auto", MinRatio: 0.9, Stats: true, Verbose: true}
+	PrintConversionDetails(results, config)
+	for _, want := range []string{"Compressed: 1, decompressed: 0", "LZ4 compressed: 1", ".yaml: 1 files", "Bytes read:"} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("output %q does not contain %q", output.String(), want)
+		}
+	}
+
+	output.Reset()
+	config.Quiet = true
+	PrintConversionDetails(results, config)
+	if output.Len() != 0 {
+		t.Errorf("quiet run printed %q", output.String())
+	}
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+// FailureKinds lists the kinds FailureKind returns, in the order failure reports show them.
+var FailureKinds = []string{"invalid footer", "size mismatch", "CRC32 mismatch", "unknown type", "permission denied", "timeout", "other error"}
+
+// maxNesting is the deepest chain of DVPL data inside DVPL data -deep follows, so crafted files cannot loop.
+const maxNesting = 16
+
+// VerifyFilesContext checks the .dvpl files in directoryOrFile concurrently with up to config.Threads
+// workers, walking and filtering files like ProcessFilesContext. Each file gets a Result with the
+// "verify" action whose Err says why it failed; the results are in no particular order unless
+// config.Threads is 1. Nothing is printed; config.OnResult can be set to observe results as they arrive.
+// A .zip file is not walked but verified entry by entry, see VerifyZipContext.
+func VerifyFilesContext(ctx context.Context, directoryOrFile string, config *Config) ([]Result, error) {
+	if isZip(directoryOrFile) {
+		return VerifyZipContext(ctx, directoryOrFile, config)
+	}
+	return walkFiles(ctx, directoryOrFile, config, (*processRun).verifyFile)
+}
+
+// verifyFile checks a single .dvpl file without writing anything.
+func (run *processRun) verifyFile(path string, info os.FileInfo) Result {
+	config := run.config
+	result := Result{Path: path, Action: "verify"}
+
+	// Verify picks the same files decompress mode would
+	modeConfig := *config
+	modeConfig.Mode = "decompress"
+	if process, reason := shouldProcess(path, &modeConfig, run.executablePath); !process {
+		result.Action = "ignore"
+		result.Reason = reason
+		return result
+	}
+
+	task := run.startFile()
+	defer task.finish(nil)
+	readStart := time.Now()
+	var fileData []byte
+	err := task.withinDeadline(nil, func(ctx context.Context) (err error) {
+		fileData, err = readFileContext(ctx, path)
+		return err
+	})
+	result.ReadTime = time.Since(readStart)
+	if err != nil {
+		// An unreadable file, such as one without read permission, fails on its own without stopping the run
+		result.Err = fmt.Errorf("reading file: %w", err)
+		return result
+	}
+	result.InputSize = int64(len(fileData))
+
+	verifyStart := time.Now()
+	var nesting int
+	err = task.withinDeadline(nil, func(ctx context.Context) (err error) {
+		nesting, err = verifyData(ctx, fileData, config)
+		return err
+	})
+	result.Elapsed = time.Since(verifyStart)
+	if !errors.Is(err, ErrFileTimeout) {
+		result.Nesting = nesting
+	}
+	result.Err = err
+	return result
+}
+
+// verifyData checks the content of a .dvpl file as configured, returning how many nested DVPL layers
+// -deep found in it. Nested layers stop being decoded once ctx is done.
+func verifyData(ctx context.Context, fileData []byte, config *Config) (nesting int, err error) {
+	// A CRC32 over the original data can only be checked by decompressing
+	switch {
+	case config.Deep:
+		var data []byte
+		data, err = decompressDVPL(fileData, config)
+		if err == nil {
+			nesting, err = verifyNested(ctx, data, config)
+		}
+	case config.LenientCRC:
+		_, err = decompressDVPL(fileData, config)
+	case config.Quick:
+		err = dvpl.VerifyDVPLChecksum(trimPadding(fileData, config))
+	default:
+		err = dvpl.ValidateDVPL(trimPadding(fileData, config))
+	}
+	return nesting, err
+}
+
+// verifyNested decompresses the DVPL data nested inside the decoded data of a file, layer after layer, until
+// the data is not DVPL any more. It returns how many nested layers were found, and fails on a layer that does
+// not decompress or on a chain deeper than maxNesting.
+func verifyNested(ctx context.Context, data []byte, config *Config) (int, error) {
+	nesting := 0
+	for dvpl.IsDVPL(data) {
+		if err := ctx.Err(); err != nil {
+			return nesting, err
+		}
+		if nesting == maxNesting {
+			return nesting, fmt.Errorf("%w: DVPL nested more than %d levels deep", dvpl.ErrInvalidFooter, maxNesting)
+		}
+		nesting++
+
+		var err error
+		data, err = decompressDVPL(data, config)
+		if err != nil {
+			return nesting, fmt.Errorf("nested DVPL at depth %d: %w", nesting, err)
+		}
+	}
+	return nesting, nil
+}
+
+// CountNested tallies the successfully verified files that -deep found nested DVPL data in.
+func CountNested(results []Result) (nestedCount int) {
+	for _, result := range results {
+		if result.Nesting > 0 && !result.Failed() {
+			nestedCount++
+		}
+	}
+	return nestedCount
+}
+
+// FailureKind classifies why a file failed as one of FailureKinds, so failure reports can group
+// corrupted downloads by cause.
+func FailureKind(err error) string {
+	switch {
+	case errors.Is(err, dvpl.ErrInvalidFooter):
+		return "invalid footer"
+	case errors.Is(err, dvpl.ErrSizeMismatch):
+		return "size mismatch"
+	case errors.Is(err, dvpl.ErrCRC32Mismatch):
+		return "CRC32 mismatch"
+	case errors.Is(err, dvpl.ErrUnknownType):
+		return "unknown type"
+	case errors.Is(err, os.ErrPermission):
+		return "permission denied"
+	case errors.Is(err, ErrFileTimeout):
+		return "timeout"
+	}
+	return "other error"
+}
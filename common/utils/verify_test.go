@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+func TestFailureKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind string
+	}{
+		{fmt.Errorf("%w: %w", dvpl.ErrInvalidFooter, dvpl.ErrFileTooSmall), "invalid footer"},
+		{fmt.Errorf("%w: decoded size differs from original size", dvpl.ErrSizeMismatch), "size mismatch"},
+		{dvpl.ErrCRC32Mismatch, "CRC32 mismatch"},
+		{fmt.Errorf("%w: type 7", dvpl.ErrUnknownType), "unknown type"},
+		{fmt.Errorf("reading file: %w after 1s", ErrFileTimeout), "timeout"},
+		{fmt.Errorf("reading file: %w", os.ErrPermission), "permission denied"},
+		{errors.New("disk on fire"), "other error"},
+	}
+
+	for _, test := range tests {
+		if kind := FailureKind(test.err); kind != test.kind {
+			t.Errorf("FailureKind(%v) = %q, want %q", test.err, kind, test.kind)
+		}
+	}
+}
+
+func TestDeepVerify(t *testing.T) {
+	nest := func(data []byte, layers int) []byte {
+		for i := 0; i < layers; i++ {
+			var err error
+			if data, err = dvpl.CompressDVPL(data); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return data
+	}
+	inner := nest([]byte("nested payload"), 1)
+	corrupt := append([]byte(nil), inner...)
+	corrupt[0] ^= 0xff
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"flat.txt.dvpl":    inner,
+		"nested.txt.dvpl":  nest(inner, 2),
+		"corrupt.txt.dvpl": nest(corrupt, 1),
+		"looped.txt.dvpl":  nest(inner, maxNesting+1),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := VerifyFilesContext(context.Background(), dir, &Config{Mode: "verify", Deep: true})
+	if err != nil || len(results) != len(files) {
+		t.Fatalf("deep verify: %+v, %v", results, err)
+	}
+	for _, result := range results {
+		switch filepath.Base(result.Path) {
+		case "flat.txt.dvpl":
+			if result.Failed() || result.Nesting != 0 {
+				t.Errorf("flat file: nesting %d, %v, want 0 and no error", result.Nesting, result.Err)
+			}
+		case "nested.txt.dvpl":
+			if result.Failed() || result.Nesting != 2 {
+				t.Errorf("nested file: nesting %d, %v, want 2 and no error", result.Nesting, result.Err)
+			}
+		case "corrupt.txt.dvpl":
+			if !errors.Is(result.Err, dvpl.ErrCRC32Mismatch) || result.Nesting != 1 {
+				t.Errorf("corrupt nested file: nesting %d, %v, want 1 and a CRC32 mismatch", result.Nesting, result.Err)
+			}
+		case "looped.txt.dvpl":
+			if !result.Failed() || result.Nesting != maxNesting {
+				t.Errorf("deeply nested file: nesting %d, %v, want %d and an error", result.Nesting, result.Err, maxNesting)
+			}
+		}
+	}
+	if nested := CountNested(results); nested != 1 {
+		t.Errorf("CountNested = %d, want 1", nested)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.yaml")
+	if err := os.WriteFile(path, []byte("canceled: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ProcessFiles(dir, &Config{Mode: "compress", KeepOriginals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(t.TempDir(), "manifest.sha256")
+	if err := WriteManifest(manifestPath, results); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	config := &Config{Quiet: true}
+
+	if stats, err := CompareDVPLFilesContext(ctx, dir, config); !errors.Is(err, context.Canceled) || stats.Success != 0 {
+		t.Errorf("compare: got %+v, %v", stats, err)
+	}
+	if stats, err := InfoDVPLFilesContext(ctx, dir, config); !errors.Is(err, context.Canceled) || stats.Success != 0 {
+		t.Errorf("info: got %+v, %v", stats, err)
+	}
+	if matchCount, _, err := ChecksumManifestContext(ctx, manifestPath, config); !errors.Is(err, context.Canceled) || matchCount != 0 {
+		t.Errorf("checksum: got %d matches, %v", matchCount, err)
+	}
+	if checks, err := RunDoctorContext(ctx); !errors.Is(err, context.Canceled) || len(checks) != 0 {
+		t.Errorf("doctor: got %d checks, %v", len(checks), err)
+	}
+
+	// The same calls with a live context see the file
+	if stats, err := CompareDVPLFilesContext(context.Background(), dir, config); err != nil || stats.Success != 1 {
+		t.Errorf("compare: got %+v, %v", stats, err)
+	}
+	if matchCount, _, err := ChecksumManifestContext(context.Background(), manifestPath, config); err != nil || matchCount != 1 {
+		t.Errorf("checksum: got %d matches, %v", matchCount, err)
+	}
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// WalkDVPL calls fn with the decompressed data of every .dvpl file in directoryOrFile, so custom tools
+// can reuse the walk and the file filtering of ProcessFiles, such as -ignore, -include, -exclude-dir and
+// -max-depth, without converting anything. It is WalkDVPLContext without a way to stop early.
+func WalkDVPL(directoryOrFile string, config *Config, fn func(path string, data []byte) error) error {
+	return WalkDVPLContext(context.Background(), directoryOrFile, config, fn)
+}
+
+// WalkDVPLContext is like WalkDVPL but stops once ctx is done. Files are read and decompressed by up to
+// config.Threads workers, and fn is called one file at a time, in no particular order unless
+// config.Threads is 1. An error returned by fn stops the walk and is returned as is. Files that cannot
+// be read or decompressed are skipped, and their errors are returned together once the walk is over,
+// unless config.FailFast stops the walk at the first of them.
+func WalkDVPLContext(ctx context.Context, directoryOrFile string, config *Config, fn func(path string, data []byte) error) error {
+	var mu sync.Mutex
+	var fnErr error
+
+	results, err := walkFiles(ctx, directoryOrFile, config, func(run *processRun, path string, info os.FileInfo) Result {
+		result := Result{Path: path, Action: "walk"}
+
+		// The walk picks the same files decompress mode would
+		modeConfig := *run.config
+		modeConfig.Mode = "decompress"
+		if process, reason := shouldProcess(path, &modeConfig, run.executablePath); !process {
+			result.Action = "ignore"
+			result.Reason = reason
+			return result
+		}
+		if tooLarge(info, run.config) {
+			result.Action = "ignore"
+			result.Reason = "file larger than -max-file-size"
+			return result
+		}
+
+		fileData, err := os.ReadFile(path)
+		if err != nil {
+			result.Err = fmt.Errorf("reading file: %w", err)
+			return result
+		}
+		result.InputSize = int64(len(fileData))
+		data, err := decompressDVPL(fileData, run.config)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		result.OutputSize = int64(len(data))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if fnErr != nil {
+			return result
+		}
+		if fnErr = fn(path, data); fnErr != nil {
+			run.cancel(fnErr)
+		}
+		return result
+	})
+
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, result := range results {
+		if result.Failed() {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Path, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
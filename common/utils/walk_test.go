@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+func TestWalkDVPL(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml.dvpl":        "name: a",
+		"sub/b.yaml.dvpl":    "name: b",
+		"sub/skip.txt.dvpl":  "name: skipped",
+		"plain/c.yaml":       "name: not compressed",
+		"sub/corrupt.x.dvpl": "",
+	}
+	for name, content := range files {
+		data, err := dvpl.CompressDVPL([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content == "" {
+			data = []byte("corrupt")
+		} else if !strings.HasSuffix(name, ".dvpl") {
+			data = []byte(content)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]string)
+	err := WalkDVPL(dir, &Config{Include: "*.yaml.dvpl, corrupt.*"}, func(path string, data []byte) error {
+		rel, _ := filepath.Rel(dir, path)
+		seen[filepath.ToSlash(rel)] = string(data)
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "corrupt.x.dvpl") || !errors.Is(err, dvpl.ErrInvalidFooter) {
+		t.Errorf("walk error = %v, want the corrupt file's ErrInvalidFooter", err)
+	}
+	if len(seen) != 2 || seen["a.yaml.dvpl"] != "name: a" || seen["sub/b.yaml.dvpl"] != "name: b" {
+		t.Errorf("walk saw %v, want the decompressed a and b files", seen)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = WalkDVPL(dir, &Config{Threads: 1, Include: "*.yaml.dvpl"}, func(string, []byte) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("walk stopped by fn: %v after %d calls, want fn's error after 1 call", err, calls)
+	}
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// isZip reports whether path names a zip archive rather than a directory or a single file to verify.
+func isZip(path string) bool {
+	if !strings.EqualFold(filepath.Ext(path), ".zip") {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// VerifyZipContext checks the .dvpl entries of a zip archive in memory, without extracting anything, as
+// VerifyFilesContext checks .dvpl files on disk. Entries are filtered like files and verified one at a
+// time in archive order. Each entry gets a Result whose Path is the archive path joined with the entry name.
+func VerifyZipContext(ctx context.Context, zipPath string, config *Config) ([]Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("processing interrupted: %w", err)
+	}
+
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	run, err := newProcessRun(ctx, config, filepath.Dir(zipPath), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer run.cancel(nil)
+
+	for _, entry := range archive.File {
+		if run.ctx.Err() != nil {
+			break
+		}
+		if strings.HasSuffix(entry.Name, "/") {
+			continue
+		}
+		run.report(run.verifyZipEntry(zipPath, entry))
+	}
+
+	if run.ctx.Err() != nil {
+		return run.results, fmt.Errorf("processing interrupted: %w", context.Cause(run.ctx))
+	}
+	return run.results, nil
+}
+
+// verifyZipEntry checks a single zip entry like verifyFile checks a file.
+func (run *processRun) verifyZipEntry(zipPath string, entry *zip.File) Result {
+	config := run.config
+	entryPath := filepath.Join(zipPath, filepath.FromSlash(entry.Name))
+	result := Result{Path: entryPath, Action: "verify"}
+
+	modeConfig := *config
+	modeConfig.Mode = "decompress"
+	if process, reason := shouldProcess(entryPath, &modeConfig, run.executablePath); !process {
+		result.Action = "ignore"
+		result.Reason = reason
+		return result
+	}
+	// Entries are decompressed into memory, so huge ones are skipped like huge files
+	if tooLarge(entry.FileInfo(), config) {
+		result.Action = "ignore"
+		result.Reason = "file larger than -max-file-size"
+		return result
+	}
+
+	task := run.startFile()
+	defer task.finish(nil)
+	readStart := time.Now()
+	var fileData []byte
+	err := task.withinDeadline(nil, func(ctx context.Context) (err error) {
+		fileData, err = readZipEntry(ctx, entry)
+		return err
+	})
+	result.ReadTime = time.Since(readStart)
+	if err != nil {
+		result.Err = fmt.Errorf("reading zip entry: %w", err)
+		return result
+	}
+	result.InputSize = int64(len(fileData))
+
+	verifyStart := time.Now()
+	var nesting int
+	err = task.withinDeadline(nil, func(ctx context.Context) (err error) {
+		nesting, err = verifyData(ctx, fileData, config)
+		return err
+	})
+	result.Elapsed = time.Since(verifyStart)
+	if !errors.Is(err, ErrFileTimeout) {
+		result.Nesting = nesting
+	}
+	result.Err = err
+	return result
+}
+
+// readZipEntry reads the whole content of a zip entry, which archive/zip checks against its CRC32.
+func readZipEntry(ctx context.Context, entry *zip.File) ([]byte, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// The size in the entry header is only checked once read, so it is not trusted for the allocation
+	return readAllContext(ctx, reader, 0)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+func TestVerifyZip(t *testing.T) {
+	valid, err := dvpl.CompressDVPL([]byte("zipped: true"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := append([]byte(nil), valid...)
+	corrupt[0] ^= 0xff
+
+	zipPath := filepath.Join(t.TempDir(), "mod.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(zipFile)
+	for _, entry := range []struct {
+		name string
+		data []byte
+	}{
+		{"Data/", nil},
+		{"Data/valid.yaml.dvpl", valid},
+		{"Data/corrupt.yaml.dvpl", corrupt},
+		{"readme.txt", []byte("not dvpl")},
+	} {
+		entryWriter, err := writer.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entryWriter.Write(entry.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zipFile.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := VerifyFilesContext(context.Background(), zipPath, &Config{Mode: "verify"})
+	if err != nil || len(results) != 3 {
+		t.Fatalf("verify zip: %+v, %v, want 3 results", results, err)
+	}
+	if success, failure, ignored := CountResults(results); success != 1 || failure != 1 || ignored != 1 {
+		t.Errorf("verify zip: %d succeeded, %d failed and %d ignored, want 1 each", success, failure, ignored)
+	}
+	for _, result := range results {
+		if result.Failed() && (result.Path != filepath.Join(zipPath, "Data", "corrupt.yaml.dvpl") || !errors.Is(result.Err, dvpl.ErrCRC32Mismatch)) {
+			t.Errorf("unexpected failure %s: %v", result.Path, result.Err)
+		}
+	}
+}
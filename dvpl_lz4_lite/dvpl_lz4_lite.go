@@ -6,7 +6,6 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"hash/crc32"
 	"log"
 	"os"
 	"path/filepath"
@@ -14,7 +13,7 @@ import (
 	"time"
 
 	"github.com/fatih/color"
-	"github.com/pierrec/lz4/v4"
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
 )
 
 // ANSI escape codes for text coloring
@@ -34,142 +33,19 @@ const Web = "https://rxd-mods.xyz"
 const Commit = "10/03/2024"
 const Info = "A CLI Tool Coded In GoLang To Convert WoTB ( Dava ) SmartDLC DVPL File Based On LZ4 High Compression."
 
-// Constants related to DVPL format
+// Process exit codes, documented in the help text.
 const (
-	dvplFooterSize = 20
-	dvplTypeNone   = 0
-	dvplTypeLZ4    = 2
-	dvplFooter     = "DVPL"
+	exitSuccess = 0 // Every file was processed successfully
+	exitFailure = 1 // At least one file failed, or the run itself failed
+	exitUsage   = 2 // Invalid command-line arguments or mode
 )
 
-// createDVPLFooter creates a DVPL footer from the provided data.
-func createDVPLFooter(inputSize, compressedSize, crc32, typeVal uint32) []byte {
-	result := make([]byte, dvplFooterSize)
-	writeLittleEndianUint32(result, inputSize, 0)
-	writeLittleEndianUint32(result, compressedSize, 4)
-	writeLittleEndianUint32(result, crc32, 8)
-	writeLittleEndianUint32(result, typeVal, 12)
-	copy(result[16:], dvplFooter)
-	return result
-}
-
-// readDVPLFooter reads the DVPL footer data from a DVPL buffer.
-func readDVPLFooter(buffer []byte) (*DVPLFooter, error) {
-	if len(buffer) < dvplFooterSize {
-		return nil, errors.New(RedColor + "InvalidDVPLFooter: Buffer size is smaller than expected" + ResetColor)
-	}
-
-	footerBuffer := buffer[len(buffer)-dvplFooterSize:]
-
-	if string(footerBuffer[16:]) != dvplFooter {
-		return nil, errors.New(RedColor + "InvalidDVPLFooter: Footer signature mismatch" + ResetColor)
-	}
-
-	footerData := &DVPLFooter{}
-	footerData.OriginalSize = readLittleEndianUint32(footerBuffer, 0)
-	footerData.CompressedSize = readLittleEndianUint32(footerBuffer, 4)
-	footerData.CRC32 = readLittleEndianUint32(footerBuffer, 8)
-	footerData.Type = readLittleEndianUint32(footerBuffer, 12)
-	return footerData, nil
-}
-
-// writeLittleEndianUint32 writes a little-endian uint32 value to a byte slice at the specified offset.
-func writeLittleEndianUint32(b []byte, v uint32, offset int) {
-	b[offset+0] = byte(v)
-	b[offset+1] = byte(v >> 8)
-	b[offset+2] = byte(v >> 16)
-	b[offset+3] = byte(v >> 24)
-}
-
-// readLittleEndianUint32 reads a little-endian uint32 value from a byte slice at the specified offset.
-func readLittleEndianUint32(b []byte, offset int) uint32 {
-	return uint32(b[offset]) | uint32(b[offset+1])<<8 | uint32(b[offset+2])<<16 | uint32(b[offset+3])<<24
-}
-
 var GlobalPath string
 
 const (
 	dvplExtension = ".dvpl"
 )
 
-// DVPLFooter represents the footer structure of a DVPL file
-type DVPLFooter struct {
-	OriginalSize   uint32 // Original size of the data
-	CompressedSize uint32 // Compressed size of the data
-	CRC32          uint32 // CRC32 checksum of the data
-	Type           uint32 // Type of compression used (0 - None, 2 - LZ4)
-}
-
-// CompressDVPL compresses a buffer and returns the processed DVPL file buffer.
-func CompressDVPL(buffer []byte) ([]byte, error) {
-	// Calculate the maximum possible compressed block size
-	compressedBlockSize := lz4.CompressBlockBound(len(buffer))
-	compressedBlock := make([]byte, compressedBlockSize)
-
-	// Compress the data
-	n, err := lz4.CompressBlock(buffer, compressedBlock, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// Trim the slice to actual compressed size
-	compressedBlock = compressedBlock[:n]
-
-	// Create DVPL footer
-	footerBuffer := createDVPLFooter(uint32(len(buffer)), uint32(n), crc32.ChecksumIEEE(compressedBlock), dvplTypeLZ4)
-
-	// Append footer to the compressed data
-	return append(compressedBlock, footerBuffer...), nil
-}
-
-// DecompressDVPL decompresses a DVPL buffer and returns the uncompressed file buffer.
-func DecompressDVPL(buffer []byte) ([]byte, error) {
-	// Read DVPL footer
-	footerData, err := readDVPLFooter(buffer)
-	if err != nil {
-		return nil, err
-	}
-
-	// Extract compressed block
-	targetBlock := buffer[:len(buffer)-dvplFooterSize]
-
-	// Check if compressed size matches the footer
-	if uint32(len(targetBlock)) != footerData.CompressedSize {
-		return nil, errors.New(RedColor + "DVPLSizeMismatch" + ResetColor)
-	}
-
-	// Check CRC32 checksum
-	if crc32.ChecksumIEEE(targetBlock) != footerData.CRC32 {
-		return nil, errors.New(RedColor + "DVPLCRC32Mismatch" + ResetColor)
-	}
-
-	// Decompress based on compression type
-	if footerData.Type == dvplTypeNone {
-		// No compression applied, return the block as is
-		if footerData.OriginalSize != footerData.CompressedSize || footerData.Type != dvplTypeNone {
-			return nil, errors.New(RedColor + "DVPLTypeSizeMismatch" + ResetColor)
-		}
-		return targetBlock, nil
-	} else if footerData.Type == dvplTypeLZ4 {
-		// LZ4 compression, decompress the block
-		deDVPLBlock := make([]byte, footerData.OriginalSize)
-		n, err := lz4.UncompressBlock(targetBlock, deDVPLBlock)
-		if err != nil {
-			return nil, err
-		}
-
-		// Check if decompressed size matches the footer
-		if uint32(n) != footerData.OriginalSize {
-			return nil, errors.New(RedColor + "DVPLDecodeSizeMismatch" + ResetColor)
-		}
-
-		return deDVPLBlock, nil
-	}
-
-	// Unknown compression type
-	return nil, errors.New(RedColor + "UNKNOWN DVPL FORMAT" + ResetColor)
-}
-
 func VerifyDVPLFiles(directoryOrFile string, config *Config) (successCount, failureCount, ignoredCount int, err error) {
 	// Initialize counters
 	successCount = 0
@@ -217,7 +93,7 @@ func VerifyDVPLFiles(directoryOrFile string, config *Config) (successCount, fail
 			return 0, 0, 0, err
 		}
 
-		_, err = DecompressDVPL(fileData)
+		_, err = dvpl.DecompressDVPL(fileData)
 		if err != nil {
 			if config.Verbose {
 				fmt.Printf("\n%sFile%s %s %sfailed to verify due to %v%s\n", RedColor, ResetColor, directoryOrFile, RedColor, err, ResetColor)
@@ -309,6 +185,12 @@ func PrintHelpMessage() {
 		-path specifies the directory/files path to process. Default is the current directory.
 		-ignore specifies comma-separated file extensions to ignore during compression.
 
+	• exit codes:
+
+		0: every file was processed successfully.
+		1: at least one file failed to convert or verify, or the run itself failed.
+		2: invalid command-line arguments or mode.
+
 	• usage can be one of the following examples:
 
 		$ dvpl_lz4 -mode help
@@ -396,10 +278,10 @@ func ProcessFiles(directoryOrFile string, config *Config) (successCount, failure
 			newName := ""
 
 			if isCompression {
-				processedBlock, err = CompressDVPL(fileData)
+				processedBlock, err = dvpl.CompressDVPL(fileData)
 				newName = directoryOrFile + dvplExtension
 			} else {
-				processedBlock, err = DecompressDVPL(fileData)
+				processedBlock, err = dvpl.DecompressDVPL(fileData)
 				newName = strings.TrimSuffix(directoryOrFile, dvplExtension)
 			}
 
@@ -462,15 +344,19 @@ func main() {
 	config, err := ParseCommandLineArgs()
 	if err != nil {
 		log.Printf("%sError%s parsing command-line arguments: %v", RedColor, ResetColor, err)
-
-		return
+		os.Exit(exitUsage)
 	}
 
 	log.SetOutput(os.Stdout) // Set log output to os.Stdout unconditionally
 
+	exitCode := exitSuccess
+
 	switch config.Mode {
 	case "compress", "decompress":
 		successCount, failureCount, ignoredCount, err := ProcessFiles(config.Path, config)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
 		if err != nil {
 			log.Printf("\n\n%s%s FAILED%s: %v\n", RedColor, strings.ToUpper(config.Mode), ResetColor, err)
 		} else {
@@ -478,6 +364,9 @@ func main() {
 		}
 	case "verify":
 		successCount, failureCount, ignoredCount, err := VerifyDVPLFiles(config.Path, config)
+		if err != nil || failureCount > 0 {
+			exitCode = exitFailure
+		}
 		if err != nil {
 			log.Printf("\n\n%s%s FAILED%s: %v\n", RedColor, strings.ToUpper(config.Mode), ResetColor, err)
 		} else {
@@ -486,9 +375,12 @@ func main() {
 	case "help":
 		PrintHelpMessage()
 	default:
-		log.Fatalf("\n%sIncorrect mode selected. Use '-help' for information.%s\n", RedColor, ResetColor)
+		log.Printf("\n%sIncorrect mode selected. Use '-help' for information.%s\n", RedColor, ResetColor)
+		os.Exit(exitUsage)
 	}
 
 	elapsedTime := time.Since(startTime) // Calculate elapsed time
 	PrintElapsedTime(elapsedTime)
+
+	os.Exit(exitCode)
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rifsxd/dvpl_lz4/common/dvpl"
+)
+
+// The lite tool must write exactly what the shared codec produces, so the two cannot drift apart again.
+func TestLiteMatchesCodec(t *testing.T) {
+	inputs := map[string][]byte{
+		"empty.txt":  nil,
+		"text.yaml":  []byte(strings.Repeat("lite and full codec must agree\n", 200)),
+		"binary.bin": {0x00, 0xff, 0x10, 0x7f, 0x80, 0x01},
+	}
+
+	dir := t.TempDir()
+	for name, data := range inputs {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := &Config{Mode: "compress", KeepOriginals: true}
+	if _, failure, _, err := ProcessFiles(dir, config); err != nil || failure != 0 {
+		t.Fatalf("ProcessFiles: %d failed, %v", failure, err)
+	}
+
+	for name, data := range inputs {
+		want, err := dvpl.CompressDVPL(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(dir, name+dvplExtension))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: lite output differs from dvpl.CompressDVPL", name)
+		}
+	}
+
+	config.Mode = "verify"
+	if success, failure, _, err := VerifyDVPLFiles(dir, config); err != nil || success != len(inputs) || failure != 0 {
+		t.Errorf("VerifyDVPLFiles: %d succeeded and %d failed, err %v, want %d, 0, nil", success, failure, err, len(inputs))
+	}
+}